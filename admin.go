@@ -0,0 +1,76 @@
+package apikit
+
+import (
+	"github.com/revel/revel"
+	"time"
+)
+
+// A RESTController that decides for itself whether admins skip the CanBe*By checks of its RESTObjects,
+// regardless of apikit.adminbypass
+type AdminBypasser interface {
+	RESTController
+	AdminBypass() bool
+}
+
+// An admin being allowed to do something that a RESTObject's CanBe*By check refused them.
+// Action is one of "view", "create", "modify" or "delete".
+type AdminOverride struct {
+	Admin   User
+	Action  string
+	Model   RESTObject
+	Request *revel.Request
+	Time    time.Time
+}
+
+// A RESTController that records the admin overrides of its RESTObjects' checks itself
+type AdminAuditor interface {
+	RESTController
+	AuditAdminOverride(override AdminOverride)
+}
+
+// Records admin overrides for RESTControllers that are not AdminAuditors
+var AdminAuditHook func(override AdminOverride)
+
+// Whether admins skip CanBe*By checks, set by apikit.adminbypass in app.conf unless the controller is an AdminBypasser
+func (c *GenericRESTController) adminBypassEnabled() bool {
+	if bypasser, ok := c.modelProvider.(AdminBypasser); ok {
+		return bypasser.AdminBypass()
+	}
+	return revel.Config.BoolDefault("apikit.adminbypass", false)
+}
+
+// Checks whether the authenticated User may perform action on model, letting admins through if bypass is enabled.
+// Custom Actions can use this in place of calling CanBe*By themselves.
+func (c *GenericRESTController) Authorize(model RESTObject, action string) bool {
+	var allowed bool
+	switch action {
+	case "view":
+		allowed = model.CanBeViewedBy(c.authenticatedUser)
+	case "create":
+		allowed = model.CanBeCreatedBy(c.authenticatedUser)
+	case "modify":
+		allowed = model.CanBeModifiedBy(c.authenticatedUser)
+	case "delete":
+		allowed = model.CanBeDeletedBy(c.authenticatedUser)
+	}
+	if allowed {
+		return true
+	}
+	if c.authenticatedUser == nil || !c.authenticatedUser.HasAdminPrivileges() || !c.adminBypassEnabled() {
+		return false
+	}
+
+	override := AdminOverride{
+		Admin: c.authenticatedUser,
+		Action: action,
+		Model: model,
+		Request: c.Request,
+		Time: time.Now(),
+	}
+	if auditor, ok := c.modelProvider.(AdminAuditor); ok {
+		auditor.AuditAdminOverride(override)
+	} else if AdminAuditHook != nil {
+		AdminAuditHook(override)
+	}
+	return true
+}
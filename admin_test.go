@@ -0,0 +1,111 @@
+package apikit
+
+import (
+	"github.com/revel/revel"
+	reveltest "github.com/revel/revel/testing"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+var (
+	adminOverridesMutex sync.Mutex
+	adminOverrides      []AdminOverride
+)
+
+// AdminAuditor interface implementation
+func (c *OwnedFishController) AuditAdminOverride(override AdminOverride) {
+	adminOverridesMutex.Lock()
+	defer adminOverridesMutex.Unlock()
+	adminOverrides = append(adminOverrides, override)
+}
+
+// AdminBypasser interface implementation; gadgets never let admins skip their checks
+func (c *GadgetController) AdminBypass() bool {
+	return false
+}
+
+func enableAdminBypass() func() {
+	revel.Config.SetOption("apikit.adminbypass", "true")
+	return func() {
+		revel.Config.SetOption("apikit.adminbypass", "false")
+	}
+}
+
+func getPrivateAquariumFishAs(suite *reveltest.TestSuite, user *ExampleUser) {
+	getUrl := "http://" + net.JoinHostPort("localhost", strconv.Itoa(testPort)) + "/aquariums/2/fish/8"
+	req := suite.GetCustom(getUrl)
+	req.SetBasicAuth(user.Username, user.Password)
+	req.MakeRequest()
+}
+
+func TestAdminBypass(t *testing.T) {
+	admin := usersDB[2]
+	suite := reveltest.NewTestSuite()
+	adminOverrides = nil
+
+	// admins are treated like everybody else by default
+	getPrivateAquariumFishAs(&suite, admin)
+	suite.AssertStatus(http.StatusForbidden)
+	suite.AssertEqual(0, len(adminOverrides))
+
+	defer enableAdminBypass()()
+	getPrivateAquariumFishAs(&suite, admin)
+	suite.AssertOk()
+
+	// the override of the aquarium's check is recorded
+	suite.AssertEqual(1, len(adminOverrides))
+	override := adminOverrides[0]
+	suite.AssertEqual(admin.ID, override.Admin.UniqueID())
+	suite.AssertEqual("view", override.Action)
+	suite.AssertEqual(aquariums[1], override.Model)
+	suite.AssertEqual("/aquariums/2/fish/8", override.Request.URL.Path)
+
+	// nobody else gets through
+	getPrivateAquariumFishAs(&suite, usersDB[0])
+	suite.AssertStatus(http.StatusForbidden)
+
+	// checks that pass anyway are not overrides
+	getPrivateAquariumFishAs(&suite, usersDB[1])
+	suite.AssertOk()
+	suite.AssertEqual(1, len(adminOverrides))
+}
+
+func TestAdminBypasser(t *testing.T) {
+	defer enableAdminBypass()()
+	suite := reveltest.NewTestSuite()
+
+	gadgets := GenericRESTController{modelProvider: (*GadgetController)(nil)}
+	suite.Assert(!gadgets.adminBypassEnabled())
+
+	fish := GenericRESTController{modelProvider: (*OwnedFishController)(nil)}
+	suite.Assert(fish.adminBypassEnabled())
+}
+
+func TestAdminAuditHook(t *testing.T) {
+	defer enableAdminBypass()()
+	audited := []AdminOverride{}
+	AdminAuditHook = func(override AdminOverride) {
+		audited = append(audited, override)
+	}
+	defer func() {
+		AdminAuditHook = nil
+	}()
+
+	c := GenericRESTController{
+		modelProvider: (*FishHookerController)(nil),
+		authenticatedUser: usersDB[2],
+	}
+	suite := reveltest.NewTestSuite()
+	suite.Assert(c.Authorize(aquariums[1], "view"))
+	suite.Assert(c.Authorize(aquariums[1], "modify"))
+	suite.AssertEqual(2, len(audited))
+	suite.AssertEqual("view", audited[0].Action)
+	suite.AssertEqual("modify", audited[1].Action)
+
+	c.authenticatedUser = usersDB[0]
+	suite.Assert(!c.Authorize(aquariums[1], "modify"))
+	suite.AssertEqual(2, len(audited))
+}
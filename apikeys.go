@@ -0,0 +1,319 @@
+package apikit
+
+import (
+	"github.com/revel/revel"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	apiKeyPrefixStart string = "ak_"
+	apiKeyHeader      string = "X-API-Key"
+	apiKeyScheme      string = "ApiKey"
+)
+
+var (
+	errInvalidAPIKey = errors.New("Invalid API key")
+	errRevokedAPIKey = errors.New("API key has been revoked")
+)
+
+// A long-lived credential owned by a User.
+// Only a hash of the secret is kept; Key holds the full key just once, in the response to its creation.
+type APIKey struct {
+	ID          uint64     `json:"id"`
+	Name        string     `json:"name"`
+	Prefix      string     `json:"prefix"`
+	OwnerID     string     `json:"owner_id"`
+	DateCreated time.Time  `json:"date_created"`
+	LastUsed    *time.Time `json:"last_used,omitempty"`
+	Revoked     *time.Time `json:"revoked,omitempty"`
+	Key         string     `json:"key,omitempty"`
+	Hash        string     `json:"-"`
+}
+
+// Where API keys are kept. Implementations must only ever be handed hashed keys.
+type APIKeyStore interface {
+	// Inserts the key if its ID is 0, assigning it a new ID, and updates it otherwise
+	SaveAPIKey(key *APIKey) error
+	GetAPIKeyByID(id uint64) *APIKey
+	GetAPIKeyByPrefix(prefix string) *APIKey
+	// At most limit of the keys owned by a User starting at offset, along with how many they own
+	ListAPIKeys(ownerID string, offset, limit int) ([]*APIKey, int)
+	MarkAPIKeyUsed(id uint64, at time.Time) error
+}
+
+// The store used by APIKeyController and APIKeyAuthenticator, in memory unless replaced
+var APIKeys APIKeyStore = NewMemoryAPIKeyStore()
+
+// An APIKeyStore that forgets its keys when the app exits
+type MemoryAPIKeyStore struct {
+	mutex  sync.RWMutex
+	keys   map[uint64]APIKey
+	lastID uint64
+}
+
+func NewMemoryAPIKeyStore() *MemoryAPIKeyStore {
+	return &MemoryAPIKeyStore{keys: map[uint64]APIKey{}}
+}
+
+func (store *MemoryAPIKeyStore) SaveAPIKey(key *APIKey) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if key.ID == 0 {
+		for _, existing := range store.keys {
+			if existing.Prefix == key.Prefix {
+				return ErrConflict{Message: "API key prefix is already in use"}
+			}
+		}
+		store.lastID++
+		key.ID = store.lastID
+	} else if _, ok := store.keys[key.ID]; !ok {
+		return ErrNotFound{Message: "API key not found"}
+	}
+	stored := *key
+	stored.Key = ""
+	store.keys[key.ID] = stored
+	return nil
+}
+
+func (store *MemoryAPIKeyStore) GetAPIKeyByID(id uint64) *APIKey {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	if key, ok := store.keys[id]; ok {
+		return &key
+	}
+	return nil
+}
+
+func (store *MemoryAPIKeyStore) GetAPIKeyByPrefix(prefix string) *APIKey {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	for _, key := range store.keys {
+		if key.Prefix == prefix {
+			return &key
+		}
+	}
+	return nil
+}
+
+func (store *MemoryAPIKeyStore) ListAPIKeys(ownerID string, offset, limit int) ([]*APIKey, int) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	owned := []*APIKey{}
+	for _, key := range store.keys {
+		if key.OwnerID == ownerID {
+			k := key
+			owned = append(owned, &k)
+		}
+	}
+	sort.Slice(owned, func(i, j int) bool {
+		return owned[i].ID < owned[j].ID
+	})
+
+	total := len(owned)
+	if offset > total {
+		offset = total
+	}
+	end := offset + limit
+	if end > total {
+		end = total
+	}
+	return owned[offset:end], total
+}
+
+func (store *MemoryAPIKeyStore) MarkAPIKeyUsed(id uint64, at time.Time) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	key, ok := store.keys[id]
+	if !ok {
+		return ErrNotFound{Message: "API key not found"}
+	}
+	key.LastUsed = &at
+	store.keys[id] = key
+	return nil
+}
+
+// Fills in a new prefix, secret and hash, returning the full key
+func (key *APIKey) generate() (string, error) {
+	random := make([]byte, 6+32)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	key.Prefix = apiKeyPrefixStart + hex.EncodeToString(random[:6])
+	secret := base64.RawURLEncoding.EncodeToString(random[6:])
+	key.Hash = hashAPIKeySecret(secret)
+	return key.Prefix + "." + secret, nil
+}
+
+func hashAPIKeySecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// RESTObject interface implementation
+
+func (key *APIKey) UniqueID() uint64 {
+	return key.ID
+}
+
+func (key *APIKey) ownedBy(user User) bool {
+	return user != nil && (userKey(user) == key.OwnerID || user.HasAdminPrivileges())
+}
+
+func (key *APIKey) CanBeViewedBy(user User) bool {
+	return key.ownedBy(user)
+}
+
+func (key *APIKey) CanBeCreatedBy(user User) bool {
+	return key.ownedBy(user)
+}
+
+func (key *APIKey) CanBeModifiedBy(user User) bool {
+	return key.ownedBy(user)
+}
+
+func (key *APIKey) CanBeDeletedBy(user User) bool {
+	return key.ownedBy(user)
+}
+
+func (key *APIKey) Validate(v *revel.Validation) {
+	v.Required(key.Name).Key("name").Message("API keys must be named")
+	v.MaxSize(key.Name, 100).Key("name").Message("API key names may be at most 100 characters")
+}
+
+func (key *APIKey) Save() error {
+	return APIKeys.SaveAPIKey(key)
+}
+
+// Revokes the key; it is kept so that its owner can still see when it was last used
+func (key *APIKey) Delete() error {
+	if key.Revoked == nil {
+		now := time.Now()
+		key.Revoked = &now
+	}
+	return APIKeys.SaveAPIKey(key)
+}
+
+// Serves the API keys of the authenticated User.
+// POST creates a key, responding with the full key this one time only; DELETE revokes one.
+type APIKeyController struct {
+	*revel.Controller
+	GenericRESTController
+}
+
+func (c *APIKeyController) ModelFactory() RESTObject {
+	return &APIKey{}
+}
+
+func (c *APIKeyController) GetModelByID(id uint64) RESTObject {
+	if key := APIKeys.GetAPIKeyByID(id); key != nil {
+		return key
+	}
+	return nil
+}
+
+func (c *APIKeyController) ListModels(offset, limit int, authUser User) ([]RESTObject, int) {
+	if authUser == nil {
+		return []RESTObject{}, 0
+	}
+	keys, total := APIKeys.ListAPIKeys(userKey(authUser), offset, limit)
+	models := make([]RESTObject, len(keys))
+	for i, key := range keys {
+		models[i] = key
+	}
+	return models, total
+}
+
+func (c *APIKeyController) EnableGET() bool {
+	return true
+}
+
+func (c *APIKeyController) EnablePOST() bool {
+	return true
+}
+
+func (c *APIKeyController) EnablePUT() bool {
+	return false
+}
+
+func (c *APIKeyController) EnableDELETE() bool {
+	return true
+}
+
+// POSTHooker interface implementation; only the name of a new key is taken from the request
+func (c *APIKeyController) PrePOSTHook(model RESTObject, authUser User) revel.Result {
+	if authUser == nil {
+		return c.DenyAccess("Sign in to create API keys")
+	}
+	key := model.(*APIKey)
+	*key = APIKey{
+		Name: key.Name,
+		OwnerID: userKey(authUser),
+		DateCreated: time.Now(),
+	}
+	full, err := key.generate()
+	if err != nil {
+		return DefaultInternalServerErrorMessage()
+	}
+	key.Key = full
+	return nil
+}
+
+func (c *APIKeyController) PostPOSTHook(model RESTObject, authUser User, err error) revel.Result {
+	return nil
+}
+
+// Authenticates the owners of API keys, sent as "Authorization: ApiKey <key>" or in an X-API-Key header.
+// Revoked keys are refused, and the last use of the others is recorded.
+// lookup is given the OwnerID of the key: the owner's ModelKey, or its UniqueID, formatted as a string.
+func APIKeyAuthenticator(lookup func(ownerID string) User) Authenticator {
+	return apiKeyAuthenticator{lookup: lookup}
+}
+
+type apiKeyAuthenticator struct {
+	lookup func(ownerID string) User
+}
+
+func (a apiKeyAuthenticator) Authenticate(req *revel.Request) (User, error) {
+	full := req.Header.Get(apiKeyHeader)
+	if authorization := req.Header.Get("Authorization"); full == "" && len(authorization) > len(apiKeyScheme) &&
+		strings.EqualFold(authorization[:len(apiKeyScheme)+1], apiKeyScheme + " ") {
+		full = strings.TrimSpace(authorization[len(apiKeyScheme)+1:])
+	}
+	if full == "" {
+		return nil, nil
+	}
+
+	dot := strings.Index(full, ".")
+	if dot == -1 {
+		return nil, errInvalidAPIKey
+	}
+	key := APIKeys.GetAPIKeyByPrefix(full[:dot])
+	if key == nil || subtle.ConstantTimeCompare([]byte(hashAPIKeySecret(full[dot+1:])), []byte(key.Hash)) != 1 {
+		return nil, errInvalidAPIKey
+	}
+	if key.Revoked != nil {
+		return nil, errRevokedAPIKey
+	}
+	owner := a.lookup(key.OwnerID)
+	if owner == nil {
+		return nil, errInvalidAPIKey
+	}
+	if err := APIKeys.MarkAPIKeyUsed(key.ID, time.Now()); err != nil {
+		revel.WARN.Println("Could not record the use of API key", strconv.FormatUint(key.ID, 10), err)
+	}
+	return owner, nil
+}
+
+func (a apiKeyAuthenticator) Challenge(realm string) string {
+	return apiKeyScheme + " realm=" + strconv.Quote(realm)
+}
@@ -0,0 +1,240 @@
+package apikit
+
+import (
+	"github.com/revel/revel"
+	reveltest "github.com/revel/revel/testing"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func testUserByID(id string) User {
+	for _, u := range usersDB {
+		if strconv.FormatUint(u.ID, 10) == id {
+			return u
+		}
+	}
+	return nil
+}
+
+func apiKeysUrl(endpoint string) string {
+	return "http://" + net.JoinHostPort("localhost", strconv.Itoa(testPort)) + endpoint
+}
+
+func createAPIKey(suite *reveltest.TestSuite, owner *ExampleUser, name string) APIKey {
+	body, _ := json.Marshal(map[string]interface{}{
+		"name": name,
+		// none of these may be chosen by the client
+		"owner_id": strconv.FormatUint(usersDB[2].ID, 10),
+		"prefix": "ak_chosen",
+		"revoked": nil,
+	})
+	req := suite.PostCustom(apiKeysUrl("/apikeys"), "application/json", bytes.NewReader(body))
+	req.SetBasicAuth(owner.Username, owner.Password)
+	req.MakeRequest()
+	suite.AssertOk()
+
+	created := APIKey{}
+	err := json.Unmarshal(suite.ResponseBody, &created)
+	suite.Assert(err == nil)
+	return created
+}
+
+func TestCreateAPIKey(t *testing.T) {
+	me := usersDB[0]
+	suite := reveltest.NewTestSuite()
+
+	created := createAPIKey(&suite, me, "CI server")
+	suite.AssertEqual("CI server", created.Name)
+	suite.AssertEqual(strconv.FormatUint(me.ID, 10), created.OwnerID)
+	suite.Assert(strings.HasPrefix(created.Prefix, "ak_") && created.Prefix != "ak_chosen")
+	suite.Assert(strings.HasPrefix(created.Key, created.Prefix + "."))
+	suite.Assert(created.LastUsed == nil)
+
+	// the key is only ever shown once
+	req := suite.GetCustom(apiKeysUrl(fmt.Sprint("/apikeys/", created.ID)))
+	req.SetBasicAuth(me.Username, me.Password)
+	req.MakeRequest()
+	suite.AssertOk()
+	suite.AssertNotContains(created.Key)
+	suite.AssertContains(created.Prefix)
+
+	// and never stored
+	stored := APIKeys.GetAPIKeyByID(created.ID)
+	suite.AssertEqual("", stored.Key)
+	suite.Assert(stored.Hash != "" && !strings.Contains(created.Key, stored.Hash))
+
+	// nobody else may see it
+	req = suite.GetCustom(apiKeysUrl(fmt.Sprint("/apikeys/", created.ID)))
+	req.SetBasicAuth(usersDB[1].Username, usersDB[1].Password)
+	req.MakeRequest()
+	suite.AssertStatus(http.StatusForbidden)
+
+	// anonymous users have no keys
+	suite.Post("/apikeys", "application/json", strings.NewReader(`{"name": "mine"}`))
+	suite.AssertStatus(http.StatusUnauthorized)
+
+	suite.Post("/apikeys", "application/json", strings.NewReader(`{}`))
+	suite.AssertStatus(http.StatusUnprocessableEntity)
+}
+
+func TestListAPIKeys(t *testing.T) {
+	owner := usersDB[1]
+	suite := reveltest.NewTestSuite()
+	first := createAPIKey(&suite, owner, "first")
+	second := createAPIKey(&suite, owner, "second")
+	createAPIKey(&suite, usersDB[0], "someone else's")
+
+	req := suite.GetCustom(apiKeysUrl("/apikeys"))
+	req.SetBasicAuth(owner.Username, owner.Password)
+	req.MakeRequest()
+	suite.AssertOk()
+
+	page := struct {
+		Items []APIKey `json:"items"`
+		Total int      `json:"total"`
+	}{}
+	err := json.Unmarshal(suite.ResponseBody, &page)
+	suite.Assert(err == nil)
+	suite.AssertEqual(2, page.Total)
+	suite.AssertEqual(first.ID, page.Items[0].ID)
+	suite.AssertEqual(second.ID, page.Items[1].ID)
+	suite.AssertNotContains(first.Key)
+}
+
+// A User identified by a UUID, whose UniqueID is the same for everyone
+type uuidUser struct {
+	UUID string
+}
+
+func (u *uuidUser) CanBeViewedBy(user User) bool {
+	return true
+}
+
+func (u *uuidUser) CanBeCreatedBy(user User) bool {
+	return false
+}
+
+func (u *uuidUser) CanBeModifiedBy(user User) bool {
+	return false
+}
+
+func (u *uuidUser) CanBeDeletedBy(user User) bool {
+	return false
+}
+
+func (u *uuidUser) Validate(v *revel.Validation) {
+}
+
+func (u *uuidUser) UniqueID() uint64 {
+	return 0
+}
+
+func (u *uuidUser) ModelKey() interface{} {
+	return u.UUID
+}
+
+func (u *uuidUser) Delete() error {
+	return nil
+}
+
+func (u *uuidUser) Save() error {
+	return nil
+}
+
+func (u *uuidUser) HasAdminPrivileges() bool {
+	return false
+}
+
+func TestAPIKeyOwnedByKeyedUser(t *testing.T) {
+	alice := &uuidUser{UUID: "3f2504e0-4f89-11d3-9a0c-0305e82c3301"}
+	bob := &uuidUser{UUID: "6ba7b810-9dad-11d1-80b4-00c04fd430c8"}
+	key := &APIKey{ID: 1, OwnerID: userKey(alice)}
+
+	if key.OwnerID != alice.UUID {
+		t.Errorf("expected the key to be owned by %s, not %s", alice.UUID, key.OwnerID)
+	}
+	if !key.CanBeViewedBy(alice) {
+		t.Error("the owner of the key should be able to view it")
+	}
+	if key.CanBeViewedBy(bob) || key.CanBeDeletedBy(bob) {
+		t.Error("another User with the same UniqueID should not own the key")
+	}
+}
+
+func TestAPIKeyAuthenticator(t *testing.T) {
+	me := usersDB[0]
+	suite := reveltest.NewTestSuite()
+	created := createAPIKey(&suite, me, "partner")
+
+	putMeWith(&suite, func(req *reveltest.TestRequest) {
+		req.Header.Set("X-API-Key", created.Key)
+	})
+	suite.AssertOk()
+	suite.Assert(APIKeys.GetAPIKeyByID(created.ID).LastUsed != nil)
+
+	putMeWith(&suite, func(req *reveltest.TestRequest) {
+		req.Header.Set("Authorization", "ApiKey " + created.Key)
+	})
+	suite.AssertOk()
+
+	// somebody else's key authenticates somebody else
+	theirs := createAPIKey(&suite, usersDB[1], "theirs")
+	putMeWith(&suite, func(req *reveltest.TestRequest) {
+		req.Header.Set("X-API-Key", theirs.Key)
+	})
+	suite.AssertStatus(http.StatusForbidden)
+
+	for _, key := range []string{
+		created.Prefix + ".wrong-secret",
+		"ak_000000000000." + strings.SplitN(created.Key, ".", 2)[1],
+		"no-dot",
+	} {
+		putMeWith(&suite, func(req *reveltest.TestRequest) {
+			req.Header.Set("X-API-Key", key)
+		})
+		suite.AssertStatus(http.StatusUnauthorized)
+		suite.AssertContains("Invalid API key")
+	}
+	challenges := suite.Response.Header[http.CanonicalHeaderKey("WWW-Authenticate")]
+	suite.Assert(containsString(challenges, `ApiKey realm="revel-apikit"`))
+}
+
+func TestRevokeAPIKey(t *testing.T) {
+	me := usersDB[0]
+	suite := reveltest.NewTestSuite()
+	created := createAPIKey(&suite, me, "leaked")
+
+	// only the owner may revoke it
+	req := suite.DeleteCustom(apiKeysUrl(fmt.Sprint("/apikeys/", created.ID)))
+	req.SetBasicAuth(usersDB[1].Username, usersDB[1].Password)
+	req.MakeRequest()
+	suite.AssertStatus(http.StatusForbidden)
+
+	// keys can revoke themselves
+	req = suite.DeleteCustom(apiKeysUrl(fmt.Sprint("/apikeys/", created.ID)))
+	req.Header.Set("X-API-Key", created.Key)
+	req.MakeRequest()
+	suite.AssertOk()
+
+	putMeWith(&suite, func(req *reveltest.TestRequest) {
+		req.Header.Set("X-API-Key", created.Key)
+	})
+	suite.AssertStatus(http.StatusUnauthorized)
+	suite.AssertContains("revoked")
+
+	// revoked keys are still listed
+	req = suite.GetCustom(apiKeysUrl(fmt.Sprint("/apikeys/", created.ID)))
+	req.SetBasicAuth(me.Username, me.Password)
+	req.MakeRequest()
+	suite.AssertOk()
+	revoked := APIKey{}
+	err := json.Unmarshal(suite.ResponseBody, &revoked)
+	suite.Assert(err == nil)
+	suite.Assert(revoked.Revoked != nil)
+}
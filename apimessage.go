@@ -1,17 +1,48 @@
 package apikit
 import (
 	"github.com/revel/revel"
-	"encoding/json"
+	"net/http"
 )
 
 // A revel.Result renderable object used to convey a status code and error message
 type ApiMessage struct {
-	StatusCode int    `json:"code"`
-	Message    string `json:"message"`
+	StatusCode int    `json:"code" xml:"code"`
+	Message    string `json:"message" xml:"message"`
+
+	// Only rendered in problem details mode, as the problem's type and extension members
+	ProblemType string                 `json:"-" xml:"-"`
+	Extensions  map[string]interface{} `json:"-" xml:"-"`
+	// Added to the response, e.g. WWW-Authenticate
+	Headers http.Header `json:"-" xml:"-"`
+
+	codec *registeredCodec
 }
 
 func (msg ApiMessage) Apply(req *revel.Request, resp *revel.Response) {
-	resp.WriteHeader(msg.StatusCode, "application/json")
-	body, _ := json.Marshal(&msg)
-	resp.Out.Write(body)
+	for name, values := range msg.Headers {
+		resp.Out.Header()[name] = values
+	}
+	if msg.StatusCode >= http.StatusBadRequest && problemDetailsEnabled() {
+		writeProblem(req, resp, msg.codec, newProblemDetails(req, msg.StatusCode, msg.ProblemType, msg.Message, msg.Extensions))
+		return
+	}
+	if err := writeEncoded(req, resp, msg.StatusCode, msg.codec, &msg); err != nil {
+		if msg.StatusCode != http.StatusInternalServerError {
+			ApiMessage{
+				StatusCode: http.StatusInternalServerError,
+				Message: "Something went wrong",
+				ProblemType: ProblemInternalServerError,
+				codec: msg.codec,
+			}.Apply(req, resp)
+			return
+		}
+		// not even the 500 could be encoded
+		resp.WriteHeader(http.StatusInternalServerError, "text/plain; charset=utf-8")
+		resp.Out.Write([]byte(http.StatusText(http.StatusInternalServerError)))
+	}
+}
+
+func (msg ApiMessage) withCodec(negotiated registeredCodec) revel.Result {
+	msg.codec = &negotiated
+	return msg
 }
@@ -3,6 +3,7 @@ package apikit
 import (
 	"reflect"
 	"errors"
+	"strings"
 )
 
 // Copies attributes that should be immutable from the source RESTObject to the dest RESTObject
@@ -44,14 +45,11 @@ func CopyImmutableAttributes(source, dest interface{}) error {
 		} else {
 			return errors.New("Destination is not a pointer to a struct")
 		}
-		const tagKeyName = "apikit"
-		const immutableKeyValue = "immutable"
-
 		for i := 0; i < vOld.NumField(); i++ {
 			oldFieldType := vOld.Type().Field(i)
 			oldFieldVal := vOld.Field(i)
 			fieldName := oldFieldType.Name
-			if oldFieldType.Tag.Get(tagKeyName) == immutableKeyValue {
+			if isImmutableField(oldFieldType) {
 				// this field was marked as immutable
 				if newField := vNew.FieldByName(fieldName); newField.IsValid() && newField.CanSet() {
 					newField.Set(vOld.FieldByName(fieldName))
@@ -73,3 +71,35 @@ func CopyImmutableAttributes(source, dest interface{}) error {
 		return nil
 	}
 }
+
+// Returns the lowercased JSON names of the attributes marked apikit:"immutable" or apikit:"createonly",
+// including those promoted from embedded structs
+func immutableAttributeNames(model interface{}) map[string]bool {
+	names := map[string]bool{}
+	t := reflect.TypeOf(model)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return names
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		jsonName := strings.Split(field.Tag.Get("json"), ",")[0]
+		if jsonName == "-" {
+			continue
+		}
+		if isImmutableField(field) {
+			if jsonName == "" {
+				jsonName = field.Name
+			}
+			names[strings.ToLower(jsonName)] = true
+		} else if field.Anonymous && jsonName == "" {
+			for name := range immutableAttributeNames(reflect.Zero(field.Type).Interface()) {
+				names[name] = true
+			}
+		}
+	}
+	return names
+}
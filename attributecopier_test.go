@@ -129,4 +129,20 @@ func TestCopyImmutableAttributesEmbedded(t *testing.T) {
 	suite.Assert(err == nil)
 	suite.Assert(originalCreateDate.Equal(updatedFish.CreateDate))
 	suite.Assert(!newCreateDate.Equal(updatedFish.CreateDate))
-}
\ No newline at end of file
+}
+
+func TestImmutableAttributeNames(t *testing.T) {
+	names := immutableAttributeNames(&Fish{})
+	if !names["createdate"] || len(names) != 1 {
+		t.Error("Fish should have exactly one immutable attribute, got", names)
+	}
+
+	names = immutableAttributeNames(&EmbeddedFish{})
+	if !names["createdate"] {
+		t.Error("Immutable attributes should be promoted from embedded structs")
+	}
+
+	if len(immutableAttributeNames(&ExampleUser{})) != 0 {
+		t.Error("ExampleUser has no struct-tagged immutable attributes")
+	}
+}
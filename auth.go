@@ -0,0 +1,150 @@
+package apikit
+
+import (
+	"github.com/revel/revel"
+	"net/http"
+	"strconv"
+)
+
+// Identifies the User making a request.
+// Return a nil User and nil error when the request does not carry the credentials this Authenticator
+// looks for, so that the next Authenticator in the chain is tried. Return an error when credentials
+// are present but invalid, and the request is answered 401 with the error's message.
+type Authenticator interface {
+	Authenticate(req *revel.Request) (User, error)
+}
+
+// Adapts an ordinary function to an Authenticator
+type AuthenticatorFunc func(req *revel.Request) (User, error)
+
+func (f AuthenticatorFunc) Authenticate(req *revel.Request) (User, error) {
+	return f(req)
+}
+
+// An Authenticator that describes the credentials it accepts in the WWW-Authenticate header of 401 responses
+type Challenger interface {
+	Authenticator
+	Challenge(realm string) string
+}
+
+// A revel controller that is not a RESTController, but is told which User the authenticators identified.
+// Requests whose credentials are invalid are answered 401 before its Actions run.
+type AuthenticatedUserReceiver interface {
+	SetAuthenticatedUser(user User)
+}
+
+type basicAuthenticator struct {
+	authFunction AuthenticationFunction
+}
+
+// Authenticates HTTP Basic credentials with an AuthenticationFunction
+func BasicAuthenticator(authFunction AuthenticationFunction) Authenticator {
+	return basicAuthenticator{authFunction: authFunction}
+}
+
+func (a basicAuthenticator) Authenticate(req *revel.Request) (User, error) {
+	if username, pass, ok := req.BasicAuth(); ok {
+		if user := a.authFunction(username, pass); user != nil {
+			return user, nil
+		}
+	}
+	return nil, nil
+}
+
+func (a basicAuthenticator) Challenge(realm string) string {
+	return "Basic realm=" + strconv.Quote(realm)
+}
+
+// Authenticates the User whose identifier is stored under key in the Revel session cookie
+func SessionAuthenticator(key string, lookup func(value string) User) Authenticator {
+	return AuthenticatorFunc(func(req *revel.Request) (User, error) {
+		cookie, err := req.Cookie(revel.CookiePrefix + "_SESSION")
+		if err != nil {
+			return nil, nil
+		}
+		if value, ok := revel.GetSessionFromCookie(cookie)[key]; ok {
+			return lookup(value), nil
+		}
+		return nil, nil
+	})
+}
+
+// Returned by an Authenticator that cannot check credentials through no fault of the client,
+// e.g. because its keys are misconfigured. The request is answered 500, and the error is logged rather than shown.
+type authenticatorFailure struct {
+	err error
+}
+
+func (failure authenticatorFailure) Error() string {
+	return failure.err.Error()
+}
+
+// Tries each Authenticator in order, stopping at the first that identifies a User or fails
+func authenticate(req *revel.Request, authenticators []Authenticator) (User, error) {
+	for _, authenticator := range authenticators {
+		user, err := authenticator.Authenticate(req)
+		if err != nil {
+			return nil, err
+		}
+		if user != nil {
+			return user, nil
+		}
+	}
+	return nil, nil
+}
+
+// The realm of the WWW-Authenticate challenge, set by apikit.realm in app.conf and defaulting to app.name
+func authRealm() string {
+	return revel.Config.StringDefault("apikit.realm", revel.Config.StringDefault("app.name", "apikit"))
+}
+
+// The result of a request whose credentials an Authenticator rejected, or could not check at all
+func (c *GenericRESTController) authenticationErrorMessage(err error) ApiMessage {
+	if _, failed := err.(authenticatorFailure); failed {
+		revel.ERROR.Println("Authentication failed:", err)
+		return DefaultInternalServerErrorMessage()
+	}
+	return c.denyAccess(err.Error(), nil)
+}
+
+// Reports that the current request may not proceed.
+// Responds 401 with a WWW-Authenticate challenge if no User was authenticated, so that clients know to send
+// credentials, or 403 if the authenticated User lacks permission. Useful for Actions provided by an ActionProvider.
+func (c *GenericRESTController) DenyAccess(message string) ApiMessage {
+	return c.denyAccess(message, nil)
+}
+
+func (c *GenericRESTController) denyAccess(message string, extensions map[string]interface{}) ApiMessage {
+	if c.authenticatedUser == nil {
+		return ApiMessage{
+			StatusCode: http.StatusUnauthorized,
+			Message: message,
+			ProblemType: ProblemNotAuthenticated,
+			Extensions: extensions,
+			Headers: http.Header{
+				"Www-Authenticate": c.challenges(),
+			},
+		}
+	}
+	return ApiMessage{
+		StatusCode: http.StatusForbidden,
+		Message: message,
+		ProblemType: ProblemNotAuthorized,
+		Extensions: extensions,
+	}
+}
+
+// One challenge for every Challenger in the chain, falling back to Basic if there are none
+func (c *GenericRESTController) challenges() []string {
+	realm := authRealm()
+	challenges := []string{}
+	for _, authenticator := range c.authenticators {
+		if challenger, ok := authenticator.(Challenger); ok {
+			challenges = append(challenges, challenger.Challenge(realm))
+		}
+	}
+	if len(challenges) == 0 {
+		challenges = append(challenges, basicAuthenticator{}.Challenge(realm))
+	}
+	return challenges
+}
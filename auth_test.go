@@ -0,0 +1,156 @@
+package apikit
+
+import (
+	"github.com/revel/revel"
+	reveltest "github.com/revel/revel/testing"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+func TestUnauthenticatedChallenge(t *testing.T) {
+	suite := reveltest.NewTestSuite()
+	body, _ := json.Marshal(usersDB[0])
+	suite.Put("/user", "application/json", bytes.NewReader(body))
+	suite.AssertStatus(http.StatusUnauthorized)
+	suite.AssertHeader("WWW-Authenticate", `Basic realm="revel-apikit"`)
+
+	suite.Get("/aquariums/2/fish/8")
+	suite.AssertStatus(http.StatusUnauthorized)
+	suite.AssertHeader("WWW-Authenticate", `Basic realm="revel-apikit"`)
+}
+
+func TestAuthenticatedForbidden(t *testing.T) {
+	endpoint := "/aquariums/2/fish/8"
+	getUrl := "http://" + net.JoinHostPort("localhost", strconv.Itoa(testPort)) + endpoint
+	suite := reveltest.NewTestSuite()
+
+	// only the aquarium's owner may see inside it
+	notOwner := usersDB[0]
+	req := suite.GetCustom(getUrl)
+	req.SetBasicAuth(notOwner.Username, notOwner.Password)
+	req.MakeRequest()
+	suite.AssertStatus(http.StatusForbidden)
+	suite.AssertEqual("", suite.Response.Header.Get("WWW-Authenticate"))
+
+	msg := ApiMessage{}
+	err := json.Unmarshal(suite.ResponseBody, &msg)
+	suite.Assert(err == nil)
+	suite.AssertEqual(http.StatusForbidden, msg.StatusCode)
+}
+
+const (
+	testTokenHeader string = "X-Test-Token"
+	testSessionKey  string = "username"
+)
+
+// Accepts a User's username reversed as their token
+type testTokenAuthenticator struct{}
+
+func (a testTokenAuthenticator) Authenticate(req *revel.Request) (User, error) {
+	token := req.Header.Get(testTokenHeader)
+	if token == "" {
+		return nil, nil
+	}
+	for _, u := range usersDB {
+		if reverse(u.Username) == token {
+			return u, nil
+		}
+	}
+	return nil, errors.New("Invalid token")
+}
+
+func (a testTokenAuthenticator) Challenge(realm string) string {
+	return "Token realm=" + strconv.Quote(realm)
+}
+
+func testSessionLookup(username string) User {
+	for _, u := range usersDB {
+		if u.Username == username {
+			return u
+		}
+	}
+	return nil
+}
+
+func reverse(s string) string {
+	runes := []rune(s)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return string(runes)
+}
+
+func putMeWith(suite *reveltest.TestSuite, authorize func(req *reveltest.TestRequest)) {
+	me := usersDB[0]
+	putUrl := "http://" + net.JoinHostPort("localhost", strconv.Itoa(testPort)) + "/user"
+	body, _ := json.Marshal(me)
+	req := suite.PutCustom(putUrl, "application/json", bytes.NewReader(body))
+	authorize(req)
+	req.MakeRequest()
+}
+
+func TestAuthenticatorChain(t *testing.T) {
+	me := usersDB[0]
+	suite := reveltest.NewTestSuite()
+
+	// the first Authenticator does not recognize a token, so the second one is tried
+	putMeWith(&suite, func(req *reveltest.TestRequest) {
+		req.Header.Set(testTokenHeader, reverse(me.Username))
+	})
+	suite.AssertOk()
+
+	// someone else's token identifies someone else
+	putMeWith(&suite, func(req *reveltest.TestRequest) {
+		req.Header.Set(testTokenHeader, reverse(usersDB[1].Username))
+	})
+	suite.AssertStatus(http.StatusForbidden)
+
+	// Basic credentials are still accepted
+	putMeWith(&suite, func(req *reveltest.TestRequest) {
+		req.SetBasicAuth(me.Username, me.Password)
+	})
+	suite.AssertOk()
+}
+
+func TestAuthenticatorError(t *testing.T) {
+	suite := reveltest.NewTestSuite()
+	putMeWith(&suite, func(req *reveltest.TestRequest) {
+		req.Header.Set(testTokenHeader, "not a token")
+	})
+	suite.AssertStatus(http.StatusUnauthorized)
+	suite.AssertContains("Invalid token")
+
+	// every Challenger in the chain describes its credentials
+	challenges := suite.Response.Header[http.CanonicalHeaderKey("WWW-Authenticate")]
+	suite.Assert(len(challenges) > 2)
+	suite.AssertEqual(`Basic realm="revel-apikit"`, challenges[0])
+	suite.AssertEqual(`Token realm="revel-apikit"`, challenges[1])
+
+	// an error stops the chain even if later credentials are valid
+	me := usersDB[0]
+	putMeWith(&suite, func(req *reveltest.TestRequest) {
+		req.Header.Set(testTokenHeader, "not a token")
+		req.AddCookie(revel.Session{testSessionKey: me.Username}.Cookie())
+	})
+	suite.AssertStatus(http.StatusUnauthorized)
+}
+
+func TestSessionAuthenticator(t *testing.T) {
+	me := usersDB[0]
+	suite := reveltest.NewTestSuite()
+	putMeWith(&suite, func(req *reveltest.TestRequest) {
+		req.AddCookie(revel.Session{testSessionKey: me.Username}.Cookie())
+	})
+	suite.AssertOk()
+
+	// a forged cookie is ignored
+	putMeWith(&suite, func(req *reveltest.TestRequest) {
+		req.AddCookie(&http.Cookie{Name: revel.CookiePrefix + "_SESSION", Value: "forged-" + testSessionKey + ":" + me.Username})
+	})
+	suite.AssertStatus(http.StatusUnauthorized)
+}
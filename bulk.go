@@ -0,0 +1,338 @@
+package apikit
+
+import (
+	"github.com/revel/revel"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Appended to a collection's path to route BulkPost and BulkPut
+	bulkPathSegment string = "_bulk"
+	defaultMaxBulkItems int = 1000
+)
+
+// A RESTController that opts in to the bulk Actions BulkPost, BulkPut and BulkDelete.
+// Each of them also requires the corresponding Enable method.
+type BulkEnabler interface {
+	RESTController
+	EnableBulk() bool
+}
+
+// A RESTController whose bulk requests can be made atomic with ?atomic=true
+type BulkSaver interface {
+	RESTController
+	BeginBulk() (BulkTransaction, error)
+}
+
+// Saves and deletes the items of an atomic bulk request in place of their own Save and Delete methods,
+// so that they are either all committed or all rolled back
+type BulkTransaction interface {
+	Save(model RESTObject) error
+	Delete(model RESTObject) error
+	Commit() error
+	Rollback() error
+}
+
+// The outcome of one item of a bulk request, in the order of the request
+type BulkItemResult struct {
+	Status  int          `json:"status" xml:"status"`
+	ID      string       `json:"id,omitempty" xml:"id,omitempty"`
+	Message string       `json:"message,omitempty" xml:"message,omitempty"`
+	Errors  []FieldError `json:"errors,omitempty" xml:"errors>error,omitempty"`
+	Item    interface{}  `json:"item,omitempty" xml:"item,omitempty"`
+}
+
+// Rendered by the bulk Actions
+type BulkResult struct {
+	Atomic    bool             `json:"atomic" xml:"atomic"`
+	Succeeded int              `json:"succeeded" xml:"succeeded"`
+	Failed    int              `json:"failed" xml:"failed"`
+	Items     []BulkItemResult `json:"items" xml:"items>item"`
+}
+
+func (item BulkItemResult) succeeded() bool {
+	return item.Status >= http.StatusOK && item.Status < http.StatusMultipleChoices
+}
+
+// Describes the result that an item of a bulk request would have had as a request of its own
+func newBulkItemResult(result revel.Result) BulkItemResult {
+	switch r := result.(type) {
+	case HookJsonResult:
+		status := r.StatusCode
+		if status == 0 {
+			status = http.StatusOK
+		}
+		return BulkItemResult{Status: status, Item: r.Body}
+	case ApiMessage:
+		return BulkItemResult{Status: r.StatusCode, Message: r.Message}
+	case noContentResult:
+		return BulkItemResult{Status: http.StatusNoContent}
+	case ValidationErrorMessage:
+		return BulkItemResult{Status: r.StatusCode, Message: r.Message, Errors: r.Errors}
+	}
+	// e.g. a hook's redirect, which cannot be followed for one item of many
+	return BulkItemResult{
+		Status: http.StatusInternalServerError,
+		Message: fmt.Sprintf("Unsupported result %T", result),
+	}
+}
+
+// The items of a bulk request being applied, within a BulkTransaction if it is atomic
+type bulkRun struct {
+	tx BulkTransaction
+	// the index of the item being applied
+	item int
+	// the Post hooks of the items applied so far, held until tx is committed
+	postHooks []heldPostHook
+}
+
+type heldPostHook struct {
+	item int
+	hook func() revel.Result
+}
+
+// Calls a Post hook once its item has been saved or deleted. Within a transaction, the hook is held until
+// the transaction is committed, so that hooks never act on items that are then rolled back. A nil run calls it now.
+func (run *bulkRun) postHook(hook func() revel.Result) revel.Result {
+	if run != nil && run.tx != nil {
+		run.postHooks = append(run.postHooks, heldPostHook{item: run.item, hook: hook})
+		return nil
+	}
+	return hook()
+}
+
+// Saves model within the run's transaction, if any. A nil run saves it on its own.
+func (run *bulkRun) save(model RESTObject) error {
+	if run != nil && run.tx != nil {
+		return run.tx.Save(model)
+	}
+	return model.Save()
+}
+
+func (run *bulkRun) delete(model RESTObject) error {
+	if run != nil && run.tx != nil {
+		return run.tx.Delete(model)
+	}
+	return model.Delete()
+}
+
+func (c *GenericRESTController) bulkEnabled() bool {
+	enabler, ok := c.modelProvider.(BulkEnabler)
+	return ok && enabler.EnableBulk()
+}
+
+// Creates each model of an array
+func (c *GenericRESTController) BulkPost() revel.Result {
+	if !c.bulkEnabled() {
+		return DefaultNotFoundMessage()
+	}
+	if !c.modelProvider.EnablePOST() {
+		return c.disabledActionMessage(DefaultNotFoundMessage())
+	}
+	parent, prematureResult := c.loadParent()
+	if prematureResult != nil {
+		return prematureResult
+	}
+	return c.unmarshalBulkRequestBody(func(instances []RESTObject) revel.Result {
+		return c.runBulk(len(instances), func(i int, run *bulkRun) BulkItemResult {
+			item := newBulkItemResult(c.create(instances[i], parent, run))
+			if item.succeeded() {
+				item.ID = c.formatID(modelKey(instances[i]))
+			}
+			return item
+		})
+	})
+}
+
+// Replaces each model of an array
+func (c *GenericRESTController) BulkPut() revel.Result {
+	if !c.bulkEnabled() {
+		return DefaultNotFoundMessage()
+	}
+	if !c.modelProvider.EnablePUT() {
+		return c.disabledActionMessage(DefaultNotFoundMessage())
+	}
+	parent, prematureResult := c.loadParent()
+	if prematureResult != nil {
+		return prematureResult
+	}
+	return c.unmarshalBulkRequestBody(func(instances []RESTObject) revel.Result {
+		return c.runBulk(len(instances), func(i int, run *bulkRun) BulkItemResult {
+			key := modelKey(instances[i])
+			item := newBulkItemResult(c.update(instances[i], parent, run))
+			item.ID = c.formatID(key)
+			return item
+		})
+	})
+}
+
+// Deletes the models named by the ids query parameter, which may be repeated or comma-separated
+func (c *GenericRESTController) BulkDelete() revel.Result {
+	if !c.bulkEnabled() {
+		return DefaultNotFoundMessage()
+	}
+	if !c.modelProvider.EnableDELETE() {
+		return c.disabledActionMessage(DefaultNotFoundMessage())
+	}
+	if _, prematureResult := c.loadParent(); prematureResult != nil {
+		return prematureResult
+	}
+	var ids []string
+	for _, param := range c.Request.URL.Query()["ids"] {
+		for _, id := range strings.Split(param, ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				ids = append(ids, id)
+			}
+		}
+	}
+	if len(ids) == 0 {
+		return ApiMessage{
+			StatusCode: http.StatusBadRequest,
+			Message: "Name the " + c.modelName() + "s to delete with the ids query parameter",
+			ProblemType: ProblemBadRequest,
+		}
+	}
+	return c.runBulk(len(ids), func(i int, run *bulkRun) BulkItemResult {
+		key, errMsg := c.parseID(ids[i])
+		if errMsg != nil {
+			return BulkItemResult{Status: errMsg.StatusCode, ID: ids[i], Message: errMsg.Message}
+		}
+		item := newBulkItemResult(c.destroy(key, run))
+		item.ID = c.formatID(key)
+		return item
+	})
+}
+
+// Decodes an array of models from the request body, each into a RESTObject from the ModelFactory
+func (c *GenericRESTController) unmarshalBulkRequestBody(next func(instances []RESTObject) revel.Result) revel.Result {
+	negotiated, ok := negotiateRequestCodec(c.Request, codecsFor(c.modelProvider))
+	if !ok {
+		return unsupportedMediaTypeMessage(requestMediaType(c.Request))
+	}
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		return DefaultBadRequestMessage()
+	}
+	var docs []interface{}
+	if _, isJSON := negotiated.codec.(JSONCodec); isJSON {
+		// keep large IDs exact
+		var doc interface{}
+		if doc, err = decodeJSONDocument(body); err == nil {
+			docs, ok = doc.([]interface{})
+		}
+	} else {
+		err = negotiated.codec.Unmarshal(body, &docs)
+	}
+	if err != nil || !ok {
+		return DefaultBadRequestMessage()
+	}
+
+	instances := make([]RESTObject, len(docs))
+	for i, doc := range docs {
+		instances[i] = c.modelProvider.ModelFactory()
+		if err := fromJSONDocument(doc, instances[i]); err != nil {
+			return DefaultBadRequestMessage()
+		}
+	}
+	return next(instances)
+}
+
+// Applies each of count items in turn. If the request is atomic, they are applied within a BulkTransaction
+// that is rolled back as soon as one of them fails, and the response takes the status of the failure.
+// Otherwise the transaction is committed, and only then are the Post hooks of the items called.
+func (c *GenericRESTController) runBulk(count int, apply func(i int, run *bulkRun) BulkItemResult) revel.Result {
+	maxItems := revel.Config.IntDefault("apikit.bulk.maxitems", defaultMaxBulkItems)
+	if count > maxItems {
+		return ApiMessage{
+			StatusCode: http.StatusRequestEntityTooLarge,
+			Message: fmt.Sprint("Bulk requests may have at most ", maxItems, " items"),
+			ProblemType: ProblemTooManyItems,
+		}
+	}
+	atomic, _ := strconv.ParseBool(c.Request.URL.Query().Get("atomic"))
+	run := &bulkRun{}
+	if atomic {
+		saver, ok := c.modelProvider.(BulkSaver)
+		if !ok {
+			return ApiMessage{
+				StatusCode: http.StatusBadRequest,
+				Message: c.modelName() + "s cannot be saved atomically",
+				ProblemType: ProblemBadRequest,
+			}
+		}
+		tx, err := saver.BeginBulk()
+		if err != nil {
+			return c.persistenceErrorResult(err, nil)
+		}
+		run.tx = tx
+	}
+
+	result := BulkResult{
+		Atomic: atomic,
+		Items: make([]BulkItemResult, 0, count),
+	}
+	status := http.StatusOK
+	for i := 0; i < count; i++ {
+		run.item = i
+		item := apply(i, run)
+		result.Items = append(result.Items, item)
+		if item.succeeded() {
+			result.Succeeded++
+			continue
+		}
+		result.Failed++
+		if atomic {
+			status = item.Status
+			break
+		}
+	}
+
+	if atomic {
+		if result.Failed > 0 {
+			if err := run.tx.Rollback(); err != nil {
+				return c.persistenceErrorResult(err, nil)
+			}
+			// nothing was saved after all
+			for i := range result.Items {
+				if result.Items[i].succeeded() {
+					result.Items[i] = BulkItemResult{
+						Status: http.StatusFailedDependency,
+						ID: result.Items[i].ID,
+						Message: "Rolled back",
+					}
+				}
+			}
+			for len(result.Items) < count {
+				result.Items = append(result.Items, BulkItemResult{
+					Status: http.StatusFailedDependency,
+					Message: "Not attempted",
+				})
+			}
+			result.Failed = count
+			result.Succeeded = 0
+		} else if err := run.tx.Commit(); err != nil {
+			return c.persistenceErrorResult(err, nil)
+		} else {
+			for _, held := range run.postHooks {
+				if prematureResult := held.hook(); prematureResult != nil {
+					item := newBulkItemResult(prematureResult)
+					item.ID = result.Items[held.item].ID
+					result.Items[held.item] = item
+					if !item.succeeded() {
+						// the item was committed all the same, but its hook failed it
+						result.Succeeded--
+						result.Failed++
+					}
+				}
+			}
+		}
+	}
+	return HookJsonResult{
+		Body: result,
+		StatusCode: status,
+	}
+}
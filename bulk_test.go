@@ -0,0 +1,300 @@
+package apikit
+
+import (
+	"github.com/revel/revel"
+	reveltest "github.com/revel/revel/testing"
+	"encoding/json"
+	"bytes"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+// A model saved in bulk, on its own or within a crateTransaction
+type Crate struct {
+	ID       uint64 `json:"id"`
+	Label    string `json:"label"`
+	Revision int    `json:"revision" apikit:"version"`
+}
+
+var (
+	warehouse      = map[uint64]Crate{}
+	warehouseMutex sync.Mutex
+	lastCrateID    uint64
+	// whether each Crate passed to PostPOSTHook had been stored by then
+	postedCratesStored []bool
+)
+
+func (crate *Crate) CanBeViewedBy(user User) bool {
+	return true
+}
+
+func (crate *Crate) CanBeCreatedBy(user User) bool {
+	return true
+}
+
+func (crate *Crate) CanBeModifiedBy(user User) bool {
+	return true
+}
+
+func (crate *Crate) CanBeDeletedBy(user User) bool {
+	return true
+}
+
+func (crate *Crate) UniqueID() uint64 {
+	return crate.ID
+}
+
+func (crate *Crate) Validate(v *revel.Validation) {
+	v.Required(crate.Label).Key("label").Message("Crates must be labelled")
+}
+
+func (crate *Crate) Save() error {
+	warehouseMutex.Lock()
+	defer warehouseMutex.Unlock()
+	if crate.ID == 0 {
+		lastCrateID++
+		crate.ID = lastCrateID
+	}
+	warehouse[crate.ID] = *crate
+	return nil
+}
+
+func (crate *Crate) Delete() error {
+	warehouseMutex.Lock()
+	defer warehouseMutex.Unlock()
+	delete(warehouse, crate.ID)
+	return nil
+}
+
+type CrateController struct {
+	*revel.Controller
+	GenericRESTController
+}
+
+func (c *CrateController) ModelFactory() RESTObject {
+	return &Crate{}
+}
+
+func (c *CrateController) GetModelByID(id uint64) RESTObject {
+	warehouseMutex.Lock()
+	defer warehouseMutex.Unlock()
+	if crate, ok := warehouse[id]; ok {
+		return &crate
+	}
+	return nil
+}
+
+func (c *CrateController) EnableGET() bool {
+	return true
+}
+
+func (c *CrateController) EnablePOST() bool {
+	return true
+}
+
+func (c *CrateController) EnablePUT() bool {
+	return true
+}
+
+func (c *CrateController) EnableDELETE() bool {
+	return true
+}
+
+// BulkEnabler interface implementation
+func (c *CrateController) EnableBulk() bool {
+	return true
+}
+
+// BulkSaver interface implementation; Crates are only written to the warehouse on Commit
+func (c *CrateController) BeginBulk() (BulkTransaction, error) {
+	return &crateTransaction{saved: map[uint64]Crate{}, deleted: map[uint64]bool{}}, nil
+}
+
+// POSTHooker interface implementation
+func (c *CrateController) PrePOSTHook(model RESTObject, authUser User) revel.Result {
+	return nil
+}
+
+func (c *CrateController) PostPOSTHook(model RESTObject, authUser User, err error) revel.Result {
+	warehouseMutex.Lock()
+	defer warehouseMutex.Unlock()
+	_, stored := warehouse[model.UniqueID()]
+	postedCratesStored = append(postedCratesStored, stored)
+	return nil
+}
+
+type crateTransaction struct {
+	saved   map[uint64]Crate
+	deleted map[uint64]bool
+}
+
+func (tx *crateTransaction) Save(model RESTObject) error {
+	crate := model.(*Crate)
+	if crate.ID == 0 {
+		warehouseMutex.Lock()
+		lastCrateID++
+		crate.ID = lastCrateID
+		warehouseMutex.Unlock()
+	}
+	tx.saved[crate.ID] = *crate
+	return nil
+}
+
+func (tx *crateTransaction) Delete(model RESTObject) error {
+	tx.deleted[model.UniqueID()] = true
+	return nil
+}
+
+func (tx *crateTransaction) Commit() error {
+	warehouseMutex.Lock()
+	defer warehouseMutex.Unlock()
+	for id, crate := range tx.saved {
+		warehouse[id] = crate
+	}
+	for id := range tx.deleted {
+		delete(warehouse, id)
+	}
+	return nil
+}
+
+func (tx *crateTransaction) Rollback() error {
+	tx.saved, tx.deleted = nil, nil
+	return nil
+}
+
+func bulkCrates(suite *reveltest.TestSuite, method, query string, crates ...Crate) BulkResult {
+	body, _ := json.Marshal(crates)
+	url := suite.BaseUrl() + "/crates/_bulk" + query
+	if method == "POST" {
+		suite.PostCustom(url, "application/json", bytes.NewReader(body)).MakeRequest()
+	} else {
+		suite.PutCustom(url, "application/json", bytes.NewReader(body)).MakeRequest()
+	}
+	return decodeBulkResult(suite)
+}
+
+func decodeBulkResult(suite *reveltest.TestSuite) BulkResult {
+	result := BulkResult{}
+	err := json.Unmarshal(suite.ResponseBody, &result)
+	suite.Assert(err == nil)
+	return result
+}
+
+func statusesOf(result BulkResult) []int {
+	statuses := make([]int, len(result.Items))
+	for i, item := range result.Items {
+		statuses[i] = item.Status
+	}
+	return statuses
+}
+
+func TestBulkPost(t *testing.T) {
+	suite := reveltest.NewTestSuite()
+
+	result := bulkCrates(&suite, "POST", "", Crate{Label: "Net"}, Crate{}, Crate{Label: "Bucket"})
+	suite.AssertOk()
+	suite.AssertEqual([]int{http.StatusOK, http.StatusUnprocessableEntity, http.StatusOK}, statusesOf(result))
+	suite.AssertEqual(2, result.Succeeded)
+	suite.AssertEqual(1, result.Failed)
+	suite.AssertEqual("label", result.Items[1].Errors[0].Key)
+	suite.AssertEqual("", result.Items[1].ID)
+
+	var id uint64
+	fmt.Sscan(result.Items[2].ID, &id)
+	suite.AssertEqual("Bucket", warehouse[id].Label)
+	suite.AssertEqual("Bucket", result.Items[2].Item.(map[string]interface{})["label"])
+}
+
+func TestAtomicBulkPost(t *testing.T) {
+	suite := reveltest.NewTestSuite()
+	stored := len(warehouse)
+
+	result := bulkCrates(&suite, "POST", "?atomic=true", Crate{Label: "Net"}, Crate{}, Crate{Label: "Bucket"})
+	suite.AssertStatus(http.StatusUnprocessableEntity)
+	suite.Assert(result.Atomic)
+	suite.AssertEqual(0, result.Succeeded)
+	suite.AssertEqual([]int{http.StatusFailedDependency, http.StatusUnprocessableEntity, http.StatusFailedDependency},
+		statusesOf(result))
+	suite.AssertEqual(stored, len(warehouse))
+
+	result = bulkCrates(&suite, "POST", "?atomic=true", Crate{Label: "Net"}, Crate{Label: "Bucket"})
+	suite.AssertOk()
+	suite.AssertEqual(2, result.Succeeded)
+	suite.AssertEqual(stored + 2, len(warehouse))
+}
+
+func TestAtomicBulkPostHooksFollowCommit(t *testing.T) {
+	suite := reveltest.NewTestSuite()
+	postedCratesStored = nil
+
+	// items that are rolled back are never reported to PostPOSTHook
+	bulkCrates(&suite, "POST", "?atomic=true", Crate{Label: "Net"}, Crate{})
+	suite.AssertStatus(http.StatusUnprocessableEntity)
+	suite.AssertEqual(0, len(postedCratesStored))
+
+	bulkCrates(&suite, "POST", "?atomic=true", Crate{Label: "Net"}, Crate{Label: "Bucket"})
+	suite.AssertOk()
+	suite.AssertEqual([]bool{true, true}, postedCratesStored)
+}
+
+func TestBulkPut(t *testing.T) {
+	suite := reveltest.NewTestSuite()
+	first, second := Crate{Label: "Net", Revision: 1}, Crate{Label: "Bucket", Revision: 1}
+	first.Save()
+	second.Save()
+
+	first.Label, second.Label = "Bigger net", "Bigger bucket"
+	second.Revision = 0
+	result := bulkCrates(&suite, "PUT", "", first, second, Crate{ID: 999999, Label: "Nowhere"})
+	suite.AssertOk()
+	suite.AssertEqual([]int{http.StatusOK, http.StatusConflict, http.StatusNotFound}, statusesOf(result))
+	suite.AssertEqual(fmt.Sprint(second.ID), result.Items[1].ID)
+	suite.AssertEqual("Bigger net", warehouse[first.ID].Label)
+	suite.AssertEqual(2, warehouse[first.ID].Revision)
+	suite.AssertEqual("Bucket", warehouse[second.ID].Label)
+}
+
+func TestBulkDelete(t *testing.T) {
+	suite := reveltest.NewTestSuite()
+	first, second, third := Crate{Label: "Net"}, Crate{Label: "Bucket"}, Crate{Label: "Rod"}
+	first.Save()
+	second.Save()
+	third.Save()
+
+	suite.Delete(fmt.Sprint("/crates?ids=", first.ID, ",", second.ID, ",nonsense&ids=999999"))
+	suite.AssertOk()
+	result := decodeBulkResult(&suite)
+	suite.AssertEqual([]int{http.StatusOK, http.StatusOK, http.StatusBadRequest, http.StatusNotFound}, statusesOf(result))
+	suite.AssertEqual("nonsense", result.Items[2].ID)
+	_, firstStored := warehouse[first.ID]
+	suite.Assert(!firstStored)
+
+	suite.Delete(fmt.Sprint("/crates?atomic=true&ids=", third.ID, ",999999"))
+	suite.AssertStatus(http.StatusNotFound)
+	_, thirdStored := warehouse[third.ID]
+	suite.Assert(thirdStored)
+
+	suite.Delete("/crates")
+	suite.AssertStatus(http.StatusBadRequest)
+}
+
+func TestBulkRequestLimits(t *testing.T) {
+	suite := reveltest.NewTestSuite()
+
+	// ExampleUsers cannot be saved in bulk
+	suite.Post("/user/_bulk", "application/json", bytes.NewBufferString("[]"))
+	suite.AssertNotFound()
+
+	suite.Post("/crates/_bulk", "application/json", bytes.NewBufferString(`{"label":"Not an array"}`))
+	suite.AssertStatus(http.StatusBadRequest)
+
+	revel.Config.SetOption("apikit.bulk.maxitems", "2")
+	defer revel.Config.SetOption("apikit.bulk.maxitems", "1000")
+	revel.Config.SetOption("apikit.problemdetails", "true")
+	defer revel.Config.SetOption("apikit.problemdetails", "false")
+	bulkCrates(&suite, "POST", "", Crate{Label: "Net"}, Crate{Label: "Bucket"}, Crate{Label: "Rod"})
+	suite.AssertStatus(http.StatusRequestEntityTooLarge)
+	suite.AssertContains("too-many-items")
+}
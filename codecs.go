@@ -0,0 +1,367 @@
+package apikit
+
+import (
+	"github.com/revel/revel"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"gopkg.in/yaml.v2"
+	msgpack "gopkg.in/vmihailenco/msgpack.v2"
+)
+
+// Encodes response bodies and decodes request bodies of one media type
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// A RESTController that only exposes some of the registered Codecs.
+// The first media type is used when a request does not specify one.
+type CodecRestrictor interface {
+	RESTController
+	MediaTypes() []string
+}
+
+type registeredCodec struct {
+	mediaType string
+	codec     Codec
+}
+
+// Registered in order of preference, so JSON is used whenever a request does not specify a media type
+var codecRegistry []registeredCodec
+
+// Registers the Codec used for bodies of the given media type, replacing any Codec already registered for it
+func RegisterCodec(mediaType string, codec Codec) {
+	mediaType = strings.ToLower(mediaType)
+	for i, registered := range codecRegistry {
+		if registered.mediaType == mediaType {
+			codecRegistry[i].codec = codec
+			return
+		}
+	}
+	codecRegistry = append(codecRegistry, registeredCodec{
+		mediaType: mediaType,
+		codec: codec,
+	})
+}
+
+func init() {
+	RegisterCodec("application/json", JSONCodec{})
+	RegisterCodec("application/xml", XMLCodec{})
+	RegisterCodec("text/xml", XMLCodec{})
+	RegisterCodec("application/x-yaml", YAMLCodec{})
+	RegisterCodec("application/yaml", YAMLCodec{})
+	RegisterCodec("application/msgpack", MessagePackCodec{})
+	RegisterCodec("application/x-msgpack", MessagePackCodec{})
+}
+
+type JSONCodec struct{}
+
+func (codec JSONCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (codec JSONCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// Uses encoding/xml, so models need xml struct tags to control their element names
+type XMLCodec struct{}
+
+func (codec XMLCodec) Marshal(v interface{}) ([]byte, error) {
+	return xml.Marshal(v)
+}
+
+func (codec XMLCodec) Unmarshal(data []byte, v interface{}) error {
+	return xml.Unmarshal(data, v)
+}
+
+// Goes through the JSON representation of models, so that json struct tags apply to YAML as well
+type YAMLCodec struct{}
+
+func (codec YAMLCodec) Marshal(v interface{}) ([]byte, error) {
+	doc, err := toJSONDocument(v)
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(doc)
+}
+
+func (codec YAMLCodec) Unmarshal(data []byte, v interface{}) error {
+	var doc interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	return fromJSONDocument(doc, v)
+}
+
+// Goes through the JSON representation of models, so that json struct tags apply to MessagePack as well
+type MessagePackCodec struct{}
+
+func (codec MessagePackCodec) Marshal(v interface{}) ([]byte, error) {
+	doc, err := toJSONDocument(v)
+	if err != nil {
+		return nil, err
+	}
+	return msgpack.Marshal(doc)
+}
+
+func (codec MessagePackCodec) Unmarshal(data []byte, v interface{}) error {
+	var doc interface{}
+	if err := msgpack.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	return fromJSONDocument(doc, v)
+}
+
+// Produces the decoded JSON document of v, with numbers converted to int64, uint64 or float64
+func toJSONDocument(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := decodeJSONDocument(data)
+	if err != nil {
+		return nil, err
+	}
+	return concreteNumbers(doc), nil
+}
+
+func concreteNumbers(doc interface{}) interface{} {
+	switch node := doc.(type) {
+	case map[string]interface{}:
+		for k, v := range node {
+			node[k] = concreteNumbers(v)
+		}
+	case []interface{}:
+		for i, v := range node {
+			node[i] = concreteNumbers(v)
+		}
+	case json.Number:
+		if i, err := node.Int64(); err == nil {
+			return i
+		}
+		if u, err := strconv.ParseUint(string(node), 10, 64); err == nil {
+			return u
+		}
+		f, _ := node.Float64()
+		return f
+	}
+	return doc
+}
+
+// Decodes a document produced by another codec into v through its JSON representation
+func fromJSONDocument(doc interface{}, v interface{}) error {
+	doc, err := stringKeys(doc)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// YAML and MessagePack decode maps with interface{} keys, which JSON cannot represent
+func stringKeys(doc interface{}) (interface{}, error) {
+	switch node := doc.(type) {
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(node))
+		for k, v := range node {
+			key, ok := k.(string)
+			if !ok {
+				return nil, fmt.Errorf("Map key %v is not a string", k)
+			}
+			value, err := stringKeys(v)
+			if err != nil {
+				return nil, err
+			}
+			converted[key] = value
+		}
+		return converted, nil
+	case map[string]interface{}:
+		for k, v := range node {
+			value, err := stringKeys(v)
+			if err != nil {
+				return nil, err
+			}
+			node[k] = value
+		}
+		return node, nil
+	case []interface{}:
+		for i, v := range node {
+			value, err := stringKeys(v)
+			if err != nil {
+				return nil, err
+			}
+			node[i] = value
+		}
+		return node, nil
+	default:
+		return doc, nil
+	}
+}
+
+// The registered codecs a RESTController exposes, in order of preference
+func codecsFor(c RESTController) []registeredCodec {
+	restrictor, ok := c.(CodecRestrictor)
+	if !ok {
+		return codecRegistry
+	}
+	var allowed []registeredCodec
+	for _, mediaType := range restrictor.MediaTypes() {
+		for _, registered := range codecRegistry {
+			if registered.mediaType == strings.ToLower(mediaType) {
+				allowed = append(allowed, registered)
+			}
+		}
+	}
+	return allowed
+}
+
+type mediaRange struct {
+	mediaType string
+	quality   float64
+}
+
+// Parses an Accept header into its media ranges, most preferred first
+func parseAccept(accept string) []mediaRange {
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		r := mediaRange{
+			mediaType: strings.ToLower(strings.TrimSpace(params[0])),
+			quality: 1,
+		}
+		if r.mediaType == "" {
+			continue
+		}
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					r.quality = q
+				}
+			}
+		}
+		if r.quality > 0 {
+			ranges = append(ranges, r)
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].quality > ranges[j].quality
+	})
+	return ranges
+}
+
+func (r mediaRange) matches(mediaType string) bool {
+	if r.mediaType == "*/*" || r.mediaType == mediaType {
+		return true
+	}
+	return strings.HasSuffix(r.mediaType, "/*") &&
+		strings.HasPrefix(mediaType, strings.TrimSuffix(r.mediaType, "*"))
+}
+
+// Chooses the codec to encode the response with from the request's Accept header.
+// Returns false if the client accepts none of the given codecs.
+func negotiateResponseCodec(req *revel.Request, codecs []registeredCodec) (registeredCodec, bool) {
+	if len(codecs) == 0 {
+		return registeredCodec{}, false
+	}
+	accept := req.Header.Get("Accept")
+	if strings.TrimSpace(accept) == "" {
+		return codecs[0], true
+	}
+	for _, r := range parseAccept(accept) {
+		for _, registered := range codecs {
+			if r.matches(registered.mediaType) {
+				return registered, true
+			}
+		}
+	}
+	return registeredCodec{}, false
+}
+
+// The media type of the request body, or "" if the request has no Content-Type.
+// Unlike revel.Request.ContentType, this does not default to text/html.
+func requestMediaType(req *revel.Request) string {
+	if req.Header.Get("Content-Type") == "" {
+		return ""
+	}
+	return req.ContentType
+}
+
+// Chooses the codec to decode the request body with from its Content-Type
+func negotiateRequestCodec(req *revel.Request, codecs []registeredCodec) (registeredCodec, bool) {
+	if len(codecs) == 0 {
+		return registeredCodec{}, false
+	}
+	mediaType := requestMediaType(req)
+	if mediaType == "" {
+		return codecs[0], true
+	}
+	for _, registered := range codecs {
+		if registered.mediaType == mediaType {
+			return registered, true
+		}
+	}
+	return registeredCodec{}, false
+}
+
+// The negotiated codec, or the best registered codec if there was no negotiation
+func responseCodec(req *revel.Request, negotiated *registeredCodec) registeredCodec {
+	if negotiated != nil {
+		return *negotiated
+	}
+	codec, ok := negotiateResponseCodec(req, codecRegistry)
+	if !ok {
+		codec = codecRegistry[0]
+	}
+	return codec
+}
+
+// Encodes body with the negotiated codec, or with the best registered codec if there was no negotiation
+func writeEncoded(req *revel.Request, resp *revel.Response, statusCode int, negotiated *registeredCodec, body interface{}) error {
+	codec := responseCodec(req, negotiated)
+	data, err := codec.codec.Marshal(body)
+	if err != nil {
+		return err
+	}
+	resp.WriteHeader(statusCode, codec.mediaType)
+	resp.Out.Write(data)
+	return nil
+}
+
+// A revel.Result that is encoded with the codec negotiated for its request
+type encodedResult interface {
+	revel.Result
+	withCodec(negotiated registeredCodec) revel.Result
+}
+
+func notAcceptableMessage(codecs []registeredCodec) ApiMessage {
+	mediaTypes := make([]string, len(codecs))
+	for i, registered := range codecs {
+		mediaTypes[i] = registered.mediaType
+	}
+	return ApiMessage{
+		StatusCode: http.StatusNotAcceptable,
+		Message: "Acceptable media types are " + strings.Join(mediaTypes, ", "),
+		ProblemType: ProblemNotAcceptable,
+		Extensions: map[string]interface{}{
+			"acceptable": mediaTypes,
+		},
+	}
+}
+
+func unsupportedMediaTypeMessage(contentType string) ApiMessage {
+	return ApiMessage{
+		StatusCode: http.StatusUnsupportedMediaType,
+		Message: "Unsupported Content-Type " + strconv.Quote(contentType),
+		ProblemType: ProblemUnsupportedMediaType,
+	}
+}
@@ -0,0 +1,157 @@
+package apikit
+
+import (
+	"github.com/revel/revel"
+	reveltest "github.com/revel/revel/testing"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"net"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// CodecRestrictor interface implementation
+func (c *GadgetController) MediaTypes() []string {
+	return []string{"application/json"}
+}
+
+func TestParseAccept(t *testing.T) {
+	ranges := parseAccept("text/*;q=0.5, application/xml, application/json;q=0.9, image/png;q=0")
+	expected := []string{"application/xml", "application/json", "text/*"}
+	if len(ranges) != len(expected) {
+		t.Fatal("Expected", expected, "got", ranges)
+	}
+	for i, r := range ranges {
+		if r.mediaType != expected[i] {
+			t.Error("Expected", expected[i], "at", i, "got", r.mediaType)
+		}
+	}
+	if !ranges[2].matches("text/xml") || ranges[2].matches("application/xml") {
+		t.Error("text/* should only match text media types")
+	}
+}
+
+func TestCodecsUseJSONNames(t *testing.T) {
+	fish := pond[0]
+	for _, codec := range []Codec{YAMLCodec{}, MessagePackCodec{}} {
+		data, err := codec.Marshal(&fish)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := codec.(YAMLCodec); ok && !strings.Contains(string(data), "fin_count: 2") {
+			t.Error("YAML should use the json struct tags, got", string(data))
+		}
+
+		decoded := Fish{}
+		if err := codec.Unmarshal(data, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if decoded.ID != fish.ID || decoded.FinCount != fish.FinCount || !decoded.CreateDate.Equal(fish.CreateDate) ||
+			len(decoded.Tags) != len(fish.Tags) || decoded.Owner.ID != fish.Owner.ID {
+			t.Errorf("%T did not round trip %v, got %v", codec, fish, decoded)
+		}
+	}
+}
+
+func TestAcceptNegotiation(t *testing.T) {
+	endpoint := fmt.Sprint("/fish/", pond[0].ID)
+	getUrl := "http://" + net.JoinHostPort("localhost", strconv.Itoa(testPort)) + endpoint
+	suite := reveltest.NewTestSuite()
+
+	req := suite.GetCustom(getUrl)
+	req.Header.Set("Accept", "application/xml")
+	req.MakeRequest()
+	suite.AssertOk()
+	suite.AssertContentType("application/xml")
+	fish := Fish{}
+	err := xml.Unmarshal(suite.ResponseBody, &fish)
+	suite.Assert(err == nil)
+	suite.AssertEqual(pond[0].ID, fish.ID)
+
+	req = suite.GetCustom(getUrl)
+	req.Header.Set("Accept", "text/html;q=0.9, application/x-yaml")
+	req.MakeRequest()
+	suite.AssertOk()
+	suite.AssertContentType("application/x-yaml")
+	fish = Fish{}
+	err = YAMLCodec{}.Unmarshal(suite.ResponseBody, &fish)
+	suite.Assert(err == nil)
+	suite.AssertEqual(pond[0].ID, fish.ID)
+
+	req = suite.GetCustom(getUrl)
+	req.Header.Set("Accept", "text/html")
+	req.MakeRequest()
+	suite.AssertStatus(http.StatusNotAcceptable)
+
+	// errors are encoded with the negotiated codec too
+	req = suite.GetCustom(fmt.Sprint(getUrl, "12345"))
+	req.Header.Set("Accept", "application/xml")
+	req.MakeRequest()
+	suite.AssertNotFound()
+	suite.AssertContentType("application/xml")
+}
+
+func TestContentTypeNegotiation(t *testing.T) {
+	suite := reveltest.NewTestSuite()
+	fish := pond[0]
+	body, _ := MessagePackCodec{}.Marshal(&fish)
+
+	suite.Put("/fish", "application/x-msgpack", bytes.NewReader(body))
+	suite.AssertOk()
+	suite.AssertContentType("application/json")
+
+	suite.Put("/fish", "text/plain", strings.NewReader("fish"))
+	suite.AssertStatus(http.StatusUnsupportedMediaType)
+
+	suite.Patch(fmt.Sprint("/fish/", fish.ID), "application/xml", strings.NewReader("<Fish></Fish>"))
+	suite.AssertStatus(http.StatusUnsupportedMediaType)
+}
+
+func TestCodecRestrictor(t *testing.T) {
+	endpoint := "/gadgets/" + gadgetShelf[0].UUID
+	getUrl := "http://" + net.JoinHostPort("localhost", strconv.Itoa(testPort)) + endpoint
+	suite := reveltest.NewTestSuite()
+
+	req := suite.GetCustom(getUrl)
+	req.Header.Set("Accept", "application/xml")
+	req.MakeRequest()
+	suite.AssertStatus(http.StatusNotAcceptable)
+
+	req = suite.GetCustom(getUrl)
+	req.Header.Set("Accept", "application/xml, application/json;q=0.1")
+	req.MakeRequest()
+	suite.AssertOk()
+	suite.AssertContentType("application/json")
+
+	suite.Put("/gadgets", "application/x-yaml", strings.NewReader("uuid: "+gadgetShelf[0].UUID))
+	suite.AssertStatus(http.StatusUnsupportedMediaType)
+}
+
+// Encodes nothing
+type brokenCodec struct{}
+
+func (codec brokenCodec) Marshal(v interface{}) ([]byte, error) {
+	return nil, errors.New("broken")
+}
+
+func (codec brokenCodec) Unmarshal(data []byte, v interface{}) error {
+	return errors.New("broken")
+}
+
+func TestApiMessageEncodingFailure(t *testing.T) {
+	broken := registeredCodec{mediaType: "application/x-broken", codec: brokenCodec{}}
+	for _, msg := range []ApiMessage{DefaultNotFoundMessage(), DefaultInternalServerErrorMessage()} {
+		recorder := httptest.NewRecorder()
+		httpReq, _ := http.NewRequest("GET", "/fish", nil)
+		msg.withCodec(broken).Apply(revel.NewRequest(httpReq), revel.NewResponse(recorder))
+		if recorder.Code != http.StatusInternalServerError || recorder.Body.Len() == 0 {
+			t.Error("Expected a 500 with a body when", msg.StatusCode, "cannot be encoded, got", recorder.Code,
+				recorder.Body.String())
+		}
+	}
+}
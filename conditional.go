@@ -0,0 +1,257 @@
+package apikit
+
+import (
+	"github.com/revel/revel"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// A RESTObject that knows its own version, e.g. a revision number or last modification time.
+// It is used as the RESTObject's entity tag in place of a hash of its rendered body, so it must not contain '"'.
+type Versioned interface {
+	RESTObject
+	Version() string
+}
+
+// The entity tag of model as rendered for the authenticated User with the negotiated codec, or "" if it cannot
+// be rendered. Versioned RESTObjects and those with an apikit:"version" field are tagged weakly with their version,
+// which names the same state whoever it is rendered for and however it is encoded.
+// Other tags are a strong hash of the rendered body and its media type.
+func (c *GenericRESTController) entityTag(model RESTObject) string {
+	if versioned, ok := model.(Versioned); ok {
+		return `W/"` + versioned.Version() + `"`
+	}
+	if version, ok := versionField(model); ok {
+		return `W/"` + fmt.Sprint(version.Interface()) + `"`
+	}
+	// hash what the User is shown, so that hidden fields cannot be guessed from the tag
+	data, err := json.Marshal(redactBody(c.present(model), c.authenticatedUser))
+	if err != nil {
+		return ""
+	}
+	sum := sha256.New()
+	sum.Write([]byte(c.responseMediaType() + "\n"))
+	sum.Write(data)
+	return `"` + hex.EncodeToString(sum.Sum(nil)[:16]) + `"`
+}
+
+// The media type the response is encoded as, which entity tags must vary with
+func (c *GenericRESTController) responseMediaType() string {
+	if c.Request == nil {
+		return ""
+	}
+	negotiated, _ := negotiateResponseCodec(c.Request, codecsFor(c.modelProvider))
+	return negotiated.mediaType
+}
+
+// Whether etag is among the comma-separated entity tags of an If-Match or If-None-Match header.
+// Weak comparison ignores the W/ prefix of weak tags, strong comparison never matches them.
+func matchesEntityTag(header, etag string, weak bool) bool {
+	if etag == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if strings.HasPrefix(candidate, "W/") {
+			if !weak {
+				continue
+			}
+			candidate = candidate[2:]
+		}
+		if candidate == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Responds 304 Not Modified to a Get whose If-None-Match header names etag, the current entity tag of its RESTObject
+func (c *GenericRESTController) checkNotModified(etag string) revel.Result {
+	if header := c.Request.Header.Get("If-None-Match"); header != "" && matchesEntityTag(header, etag, true) {
+		return notModifiedResult{etag: etag}
+	}
+	return nil
+}
+
+// Responds 412 Precondition Failed to a request that changes model unless its If-Match header, if any,
+// names the current entity tag of model. Weak tags are compared weakly: they are versions, which name the state
+// that would be changed just as well as a strong tag.
+func (c *GenericRESTController) checkPrecondition(model RESTObject, key interface{}, action string) *ApiMessage {
+	header := c.Request.Header.Get("If-Match")
+	etag := c.entityTag(model)
+	if header == "" || matchesEntityTag(header, etag, strings.HasPrefix(etag, "W/")) {
+		return nil
+	}
+	return &ApiMessage{
+		StatusCode: http.StatusPreconditionFailed,
+		Message: "If-Match does not name the current version of " + c.modelName() + " with ID " + c.formatID(key),
+		ProblemType: ProblemPreconditionFailed,
+		Extensions: c.modelExtensions(key, action),
+	}
+}
+
+// The integer field of a pointer to a struct marked apikit:"version", looking into embedded structs
+func versionField(model interface{}) (reflect.Value, bool) {
+	v := reflect.ValueOf(model)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return reflect.Value{}, false
+	}
+	return structVersionField(v.Elem())
+}
+
+func structVersionField(v reflect.Value) (reflect.Value, bool) {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if hasTagOption(field, versionKeyValue) {
+			switch field.Type.Kind() {
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+				reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+				return v.Field(i), true
+			}
+		} else if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			if version, ok := structVersionField(v.Field(i)); ok {
+				return version, true
+			}
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// Responds 409 Conflict to a PUT or PATCH whose version differs from the stored version of its RESTObject
+func (c *GenericRESTController) checkVersion(preExisting, instance RESTObject, key interface{}) *ApiMessage {
+	stored, ok := versionField(preExisting)
+	if !ok {
+		return nil
+	}
+	given, ok := versionField(instance)
+	if !ok || given.Interface() == stored.Interface() {
+		return nil
+	}
+	extensions := c.modelExtensions(key, "modify")
+	extensions["current_version"] = stored.Interface()
+	extensions["given_version"] = given.Interface()
+	return &ApiMessage{
+		StatusCode: http.StatusConflict,
+		Message: fmt.Sprint(c.modelName(), " with ID ", c.formatID(key), " is at version ", stored.Interface(),
+			", not ", given.Interface()),
+		ProblemType: ProblemConflict,
+		Extensions: extensions,
+	}
+}
+
+// The JSON name of the field marked apikit:"version", looking into embedded structs
+func versionFieldName(t reflect.Type) (string, bool) {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return "", false
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if hasTagOption(field, versionKeyValue) {
+			return jsonFieldName(field)
+		} else if field.Anonymous {
+			if name, ok := versionFieldName(field.Type); ok {
+				return name, true
+			}
+		}
+	}
+	return "", false
+}
+
+// Whether a merge patch sets the top-level attribute name, or a JSON Patch adds, replaces or tests it.
+// Names match regardless of case, as they do when encoding/json decodes the patched model.
+func patchNamesAttribute(patch []byte, name string) bool {
+	doc, err := decodeJSONDocument(patch)
+	if err != nil {
+		return false
+	}
+	if members, ok := doc.(map[string]interface{}); ok {
+		for member := range members {
+			if strings.EqualFold(member, name) {
+				return true
+			}
+		}
+		return false
+	}
+	ops, err := parseJSONPatch(patch)
+	if err != nil {
+		return false
+	}
+	for _, op := range ops {
+		switch op.Op {
+		case "add", "replace", "test":
+			if len(op.path) == 1 && strings.EqualFold(op.path[0], name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Responds 428 Precondition Required to a Patch of a model with an apikit:"version" field that names neither
+// the version it is based on nor an If-Match header, since it would otherwise overwrite changes it never saw
+func (c *GenericRESTController) checkPatchVersion(preExisting RESTObject, patch []byte, key interface{}) *ApiMessage {
+	if _, ok := versionField(preExisting); !ok || c.Request.Header.Get("If-Match") != "" {
+		return nil
+	}
+	name, ok := versionFieldName(reflect.TypeOf(preExisting))
+	if !ok || patchNamesAttribute(patch, name) {
+		return nil
+	}
+	extensions := c.modelExtensions(key, "modify")
+	extensions["version_attribute"] = name
+	return &ApiMessage{
+		StatusCode: http.StatusPreconditionRequired,
+		Message: fmt.Sprint("Patch of ", c.modelName(), " with ID ", c.formatID(key), " must include its ", name,
+			" or an If-Match header"),
+		ProblemType: ProblemPreconditionRequired,
+		Extensions: extensions,
+	}
+}
+
+// Increments the apikit:"version" field of model, if it has one, before it is saved
+func incrementVersion(model RESTObject) {
+	if version, ok := versionField(model); ok && version.CanSet() {
+		switch version.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			version.SetInt(version.Int() + 1)
+		default:
+			version.SetUint(version.Uint() + 1)
+		}
+	}
+}
+
+// The HookJsonResult rendering model, tagged with its entity tag
+func (c *GenericRESTController) modelResult(model RESTObject) HookJsonResult {
+	result := HookJsonResult{
+		Body: c.present(model),
+	}
+	if etag := c.entityTag(model); etag != "" {
+		result.Headers = http.Header{}
+		result.Headers.Set("ETag", etag)
+		// caches must not answer a request for one media type with the tag of another
+		result.Headers.Set("Vary", "Accept")
+	}
+	return result
+}
+
+type notModifiedResult struct {
+	etag string
+}
+
+func (result notModifiedResult) Apply(req *revel.Request, resp *revel.Response) {
+	resp.Out.Header().Set("ETag", result.etag)
+	resp.Out.Header().Set("Vary", "Accept")
+	resp.Status = http.StatusNotModified
+	resp.Out.WriteHeader(http.StatusNotModified)
+}
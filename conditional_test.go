@@ -0,0 +1,160 @@
+package apikit
+
+import (
+	reveltest "github.com/revel/revel/testing"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+// A Gadget that keeps count of its revisions
+type revisedGadget struct {
+	Gadget
+	Revision int `json:"revision"`
+}
+
+// Versioned interface implementation
+func (g *revisedGadget) Version() string {
+	return strconv.Itoa(g.Revision)
+}
+
+func TestMatchesEntityTag(t *testing.T) {
+	for _, example := range []struct {
+		header string
+		weak   bool
+		match  bool
+	}{
+		{`"abc"`, false, true},
+		{`"xyz", "abc"`, false, true},
+		{`"xyz"`, true, false},
+		{`W/"abc"`, true, true},
+		{`W/"abc"`, false, false},
+		{`*`, false, true},
+	} {
+		if matchesEntityTag(example.header, `"abc"`, example.weak) != example.match {
+			t.Errorf("Expected %q matching %t with weak comparison %t", example.header, example.match, example.weak)
+		}
+	}
+	if matchesEntityTag("*", "", true) {
+		t.Error("Nothing should match a RESTObject without an entity tag")
+	}
+}
+
+func TestEntityTag(t *testing.T) {
+	gadgets := GenericRESTController{modelProvider: (*GadgetController)(nil)}
+	gadget := &revisedGadget{Gadget: Gadget{UUID: "1b4e28ba", Name: "Whisk"}, Revision: 3}
+	if etag := gadgets.entityTag(gadget); etag != `W/"3"` {
+		t.Errorf(`Expected the entity tag of a Versioned RESTObject to be W/"3", got %s`, etag)
+	}
+
+	// otherwise the tag changes with the rendered body
+	before := gadgets.entityTag(&gadget.Gadget)
+	gadget.Name = "Whisk 2"
+	if after := gadgets.entityTag(&gadget.Gadget); after == before {
+		t.Errorf("Expected the entity tag to change with the RESTObject, got %s both times", after)
+	}
+}
+
+func TestIfNoneMatch(t *testing.T) {
+	me := usersDB[0]
+	endpoint := fmt.Sprint("/user/", me.ID)
+	getUrl := "http://" + net.JoinHostPort("localhost", strconv.Itoa(testPort)) + endpoint
+	suite := reveltest.NewTestSuite()
+
+	suite.Get(endpoint)
+	suite.AssertOk()
+	etag := suite.Response.Header.Get("ETag")
+	suite.Assert(etag != "")
+
+	for _, header := range []string{etag, "W/" + etag, `"stale", ` + etag, "*"} {
+		req := suite.GetCustom(getUrl)
+		req.Header.Set("If-None-Match", header)
+		req.MakeRequest()
+		suite.AssertStatus(http.StatusNotModified)
+		suite.AssertHeader("ETag", etag)
+		suite.AssertEqual(0, len(suite.ResponseBody))
+	}
+
+	req := suite.GetCustom(getUrl)
+	req.Header.Set("If-None-Match", `"stale"`)
+	req.MakeRequest()
+	suite.AssertOk()
+	suite.AssertHeader("ETag", etag)
+}
+
+func TestEntityTagVariesWithMediaType(t *testing.T) {
+	me := usersDB[0]
+	getUrl := "http://" + net.JoinHostPort("localhost", strconv.Itoa(testPort)) + fmt.Sprint("/user/", me.ID)
+	suite := reveltest.NewTestSuite()
+	getAs := func(mediaType, ifNoneMatch string) {
+		req := suite.GetCustom(getUrl)
+		req.Header.Set("Accept", mediaType)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		req.MakeRequest()
+	}
+
+	getAs("application/json", "")
+	suite.AssertOk()
+	suite.AssertHeader("Vary", "Accept")
+	jsonTag := suite.Response.Header.Get("ETag")
+
+	getAs("application/xml", "")
+	suite.AssertOk()
+	suite.AssertContentType("application/xml")
+	suite.AssertHeader("Vary", "Accept")
+	xmlTag := suite.Response.Header.Get("ETag")
+	suite.Assert(xmlTag != "" && xmlTag != jsonTag)
+
+	// a cached JSON body is no substitute for XML
+	getAs("application/xml", jsonTag)
+	suite.AssertOk()
+	suite.AssertContentType("application/xml")
+
+	getAs("application/xml", xmlTag)
+	suite.AssertStatus(http.StatusNotModified)
+	suite.AssertHeader("Vary", "Accept")
+}
+
+func TestIfMatch(t *testing.T) {
+	me := usersDB[0]
+	endpoint := fmt.Sprint("/user/", me.ID)
+	deleteUrl := "http://" + net.JoinHostPort("localhost", strconv.Itoa(testPort)) + endpoint
+	suite := reveltest.NewTestSuite()
+
+	requestAs(&suite, me, "GET", endpoint, nil)
+	suite.AssertOk()
+	etag := suite.Response.Header.Get("ETag")
+
+	for _, header := range []string{`"stale"`, "W/" + etag} {
+		putMeWith(&suite, func(req *reveltest.TestRequest) {
+			req.SetBasicAuth(me.Username, me.Password)
+			req.Header.Set("If-Match", header)
+		})
+		suite.AssertStatus(http.StatusPreconditionFailed)
+
+		req := suite.DeleteCustom(deleteUrl)
+		req.SetBasicAuth(me.Username, me.Password)
+		req.Header.Set("If-Match", header)
+		req.MakeRequest()
+		suite.AssertStatus(http.StatusPreconditionFailed)
+	}
+
+	for _, header := range []string{etag, "*"} {
+		putMeWith(&suite, func(req *reveltest.TestRequest) {
+			req.SetBasicAuth(me.Username, me.Password)
+			req.Header.Set("If-Match", header)
+		})
+		suite.AssertOk()
+		suite.AssertHeader("ETag", etag)
+	}
+
+	req := suite.DeleteCustom(deleteUrl)
+	req.SetBasicAuth(me.Username, me.Password)
+	req.Header.Set("If-Match", etag)
+	req.MakeRequest()
+	suite.AssertOk()
+}
@@ -5,183 +5,430 @@ import (
 	"fmt"
 	"net/http"
 	"reflect"
-	"encoding/json"
+	"io/ioutil"
+	"net/url"
 )
 
 type GenericRESTController struct {
 	authenticatedUser User
 	Request           *revel.Request
 	modelProvider     RESTController
+	routeParams       url.Values
+	parentIDs         []string
+	authenticators    []Authenticator
 }
 
 const (
 	RESTControllerName string = "GenericRESTController"
 )
 
-func (c *GenericRESTController) Get(id uint64) revel.Result {
+func (c *GenericRESTController) Get(id interface{}) revel.Result {
 	if !c.modelProvider.EnableGET() {
-		return DefaultBadRequestMessage()
+		return c.disabledActionMessage(DefaultBadRequestMessage())
+	}
+	key, errMsg := c.parseID(id)
+	if errMsg != nil {
+		return *errMsg
+	}
+	if _, prematureResult := c.loadParent(); prematureResult != nil {
+		return prematureResult
 	}
-	if hooker, ok := c.modelProvider.(GETHooker); ok {
-		if prematureResult := hooker.PreGETHook(id, c.authenticatedUser); prematureResult != nil {
+	if hooker, ok := c.modelProvider.(KeyedGETHooker); ok {
+		if prematureResult := hooker.PreGETHookKey(key, c.authenticatedUser); prematureResult != nil {
 			return prematureResult
 		}
+	} else if hooker, ok := c.modelProvider.(GETHooker); ok {
+		if numericID, ok := key.(uint64); ok {
+			if prematureResult := hooker.PreGETHook(numericID, c.authenticatedUser); prematureResult != nil {
+				return prematureResult
+			}
+		}
 	}
-	if found := c.modelProvider.GetModelByID(id); found == nil {
+	if found := c.findModel(key); found == nil {
 		return ApiMessage{
 			StatusCode: http.StatusNotFound,
-			Message: fmt.Sprint(c.modelName(), " with ID ", id, " not found"),
-		}
-	} else if !found.CanBeViewedBy(c.authenticatedUser) {
-		return ApiMessage{
-			StatusCode: http.StatusUnauthorized,
-			Message: fmt.Sprint("Unauthorized to view ", c.modelName(), " with ID ", id),
+			Message: fmt.Sprint(c.modelName(), " with ID ", c.formatID(key), " not found"),
+			ProblemType: ProblemNotFound,
+			Extensions: c.modelExtensions(key, ""),
 		}
+	} else if !c.Authorize(found, "view") {
+		return c.denyAccess(fmt.Sprint("Unauthorized to view ", c.modelName(), " with ID ", c.formatID(key)), c.modelExtensions(key, "view"))
 	} else {
 		if hooker, ok := c.modelProvider.(GETHooker); ok {
 			if prematureResult := hooker.PostGETHook(found, c.authenticatedUser); prematureResult != nil {
 				return prematureResult
 			}
+		} else if hooker, ok := c.modelProvider.(KeyedGETHooker); ok {
+			if prematureResult := hooker.PostGETHook(found, c.authenticatedUser); prematureResult != nil {
+				return prematureResult
+			}
 		}
-		return HookJsonResult{
-			Body: found,
+		result := c.modelResult(found)
+		if notModified := c.checkNotModified(result.Headers.Get("ETag")); notModified != nil {
+			return notModified
+		}
+		return result
+	}
+}
+
+func (c *GenericRESTController) List() revel.Result {
+	if !c.modelProvider.EnableGET() {
+		// listing is viewing every RESTObject at once
+		return c.disabledActionMessage(DefaultBadRequestMessage())
+	}
+	parent, prematureResult := c.loadParent()
+	if prematureResult != nil {
+		return prematureResult
+	}
+	offset, limit, errMsg := parsePageParams(c.Request.URL.Query())
+	if errMsg != nil {
+		return *errMsg
+	}
+
+	var models []RESTObject
+	var total int
+	if scopedLister, ok := c.modelProvider.(ScopedModelLister); ok {
+		models, total = scopedLister.ListScopedModels(parent, offset, limit, c.authenticatedUser)
+	} else if lister, ok := c.modelProvider.(ModelLister); ok && parent == nil {
+		models, total = lister.ListModels(offset, limit, c.authenticatedUser)
+	} else {
+		return DefaultNotFoundMessage()
+	}
+	page := ModelPage{
+		Items: []interface{}{},
+		Total: total,
+		Offset: offset,
+		Limit: limit,
+	}
+	for _, model := range models {
+		// silently leave out anything the user is not allowed to see
+		if c.Authorize(model, "view") {
+			page.Items = append(page.Items, c.present(model))
 		}
 	}
+	page.setLinks(c.Request.URL)
+
+	return HookJsonResult{
+		Body: page,
+	}
 }
 
 func (c *GenericRESTController) Post() revel.Result {
 	instance := c.modelProvider.ModelFactory()
 	if !c.modelProvider.EnablePOST() {
-		return DefaultNotFoundMessage()
+		return c.disabledActionMessage(DefaultNotFoundMessage())
+	}
+	parent, prematureResult := c.loadParent()
+	if prematureResult != nil {
+		return prematureResult
+	}
+	return c.idempotent(func() revel.Result {
+		return c.unmarshalRequestBody(instance, func() revel.Result {
+			return c.create(instance, parent, nil)
+		})
+	})
+}
+
+// Creates instance, decoded from a request, as one item of run, or on its own if run is nil
+func (c *GenericRESTController) create(instance, parent RESTObject, run *bulkRun) revel.Result {
+	applyFieldRules(instance, nil, c.authenticatedUser)
+	c.linkToParent(instance, parent)
+	if invalid := validateModel(instance); invalid != nil {
+		return *invalid
+	}
+	if hooker, ok := c.modelProvider.(POSTHooker); ok {
+		if prematureResult := hooker.PrePOSTHook(instance, c.authenticatedUser); prematureResult != nil {
+			return prematureResult
+		}
+	}
+	if !c.Authorize(instance, "create") {
+		return c.denyAccess("Not authorized to post this " + c.modelName(), c.modelExtensions(nil, "create"))
 	}
-	return c.unmarshalRequestBody(&instance, func() revel.Result {
+	incrementVersion(instance)
+	if err := run.save(instance); err != nil {
+		return c.persistenceErrorResult(err, nil)
+	} else {
 		if hooker, ok := c.modelProvider.(POSTHooker); ok {
-			if prematureResult := hooker.PrePOSTHook(instance, c.authenticatedUser); prematureResult != nil {
+			if prematureResult := run.postHook(func() revel.Result {
+				return hooker.PostPOSTHook(instance, c.authenticatedUser, err)
+			}); prematureResult != nil {
 				return prematureResult
 			}
 		}
-		if !instance.CanBeCreatedBy(c.authenticatedUser) {
-			return ApiMessage{
-				StatusCode: http.StatusUnauthorized,
-				Message: "Not authorized to post this " + c.modelName(),
-			}
-		}
-		if err := instance.Save(); err != nil {
-			return ApiMessage{
-				StatusCode: http.StatusBadRequest,
-				Message: err.Error(),
-			}
-		} else {
-			if hooker, ok := c.modelProvider.(POSTHooker); ok {
-				if prematureResult := hooker.PostPOSTHook(instance, c.authenticatedUser, err); prematureResult != nil {
-					return prematureResult
+		result := c.modelResult(instance)
+		if httpSemanticsEnabled() {
+			result.StatusCode = http.StatusCreated
+			if location := c.location(instance); location != "" {
+				if result.Headers == nil {
+					result.Headers = http.Header{}
 				}
-			}
-			return HookJsonResult{
-				Body: instance,
+				result.Headers.Set("Location", location)
 			}
 		}
-	})
+		return result
+	}
 }
 
 func (c *GenericRESTController) Put() revel.Result {
 	instance := c.modelProvider.ModelFactory()
 	if !c.modelProvider.EnablePUT() {
-		return DefaultNotFoundMessage()
+		return c.disabledActionMessage(DefaultNotFoundMessage())
 	}
-	return c.unmarshalRequestBody(&instance, func() revel.Result {
-		// ensure that this is a pre-existing record
-		preExisting := c.modelProvider.GetModelByID(instance.UniqueID())
-		if preExisting == nil {
-			return ApiMessage{
-				StatusCode: http.StatusNotFound,
-				Message: fmt.Sprint(c.modelName(), " with ID ", instance.UniqueID(), " does not exist"),
-			}
+	parent, prematureResult := c.loadParent()
+	if prematureResult != nil {
+		return prematureResult
+	}
+	return c.unmarshalRequestBody(instance, func() revel.Result {
+		return c.update(instance, parent, nil)
+	})
+}
+
+// Replaces the stored RESTObject with instance, decoded from a request, as one item of run, or on its own if run is nil
+func (c *GenericRESTController) update(instance, parent RESTObject, run *bulkRun) revel.Result {
+	c.linkToParent(instance, parent)
+	// ensure that this is a pre-existing record
+	key := modelKey(instance)
+	preExisting := c.findModel(key)
+	if preExisting == nil {
+		return ApiMessage{
+			StatusCode: http.StatusNotFound,
+			Message: fmt.Sprint(c.modelName(), " with ID ", c.formatID(key), " does not exist"),
+			ProblemType: ProblemNotFound,
+			Extensions: c.modelExtensions(key, ""),
 		}
-		if err := CopyImmutableAttributes(preExisting, instance); err != nil {
-			return DefaultInternalServerErrorMessage()
+	}
+	if run == nil {
+		// the preconditions of bulk requests concern the whole collection, not its items
+		if failed := c.checkPrecondition(preExisting, key, "modify"); failed != nil {
+			return *failed
 		}
+	}
+	applyFieldRules(instance, preExisting, c.authenticatedUser)
+	if conflict := c.checkVersion(preExisting, instance, key); conflict != nil {
+		return *conflict
+	}
+	if err := CopyImmutableAttributes(preExisting, instance); err != nil {
+		return DefaultInternalServerErrorMessage()
+	}
+	if invalid := validateModel(instance); invalid != nil {
+		return *invalid
+	}
+	if hooker, ok := c.modelProvider.(PUTHooker); ok {
+		if prematureResult := hooker.PrePUTHook(instance, preExisting, c.authenticatedUser); prematureResult != nil {
+			return prematureResult
+		}
+	}
+
+
+	if !c.Authorize(instance, "modify") {
+		return c.denyAccess("Not authorized to modify this " + c.modelName(), c.modelExtensions(key, "modify"))
+	}
+	incrementVersion(instance)
+	if err := run.save(instance); err != nil {
+		return c.persistenceErrorResult(err, key)
+	} else {
 		if hooker, ok := c.modelProvider.(PUTHooker); ok {
-			if prematureResult := hooker.PrePUTHook(instance, preExisting, c.authenticatedUser); prematureResult != nil {
+			if prematureResult := run.postHook(func() revel.Result {
+				return hooker.PostPUTHook(instance, preExisting, c.authenticatedUser, err)
+			}); prematureResult != nil {
 				return prematureResult
 			}
 		}
+		return c.modelResult(instance)
+	}
+}
 
-
-		if !instance.CanBeModifiedBy(c.authenticatedUser) {
-			return ApiMessage{
-				StatusCode: http.StatusUnauthorized,
-				Message: "Not authorized to modify this " + c.modelName(),
-			}
+func (c *GenericRESTController) Patch(id interface{}) revel.Result {
+	if enabler, ok := c.modelProvider.(PATCHEnabler); !ok || !enabler.EnablePATCH() {
+		return c.disabledActionMessage(DefaultNotFoundMessage())
+	}
+	key, errMsg := c.parseID(id)
+	if errMsg != nil {
+		return *errMsg
+	}
+	parent, prematureResult := c.loadParent()
+	if prematureResult != nil {
+		return prematureResult
+	}
+	preExisting := c.findModel(key)
+	if preExisting == nil {
+		return ApiMessage{
+			StatusCode: http.StatusNotFound,
+			Message: fmt.Sprint(c.modelName(), " with ID ", c.formatID(key), " not found"),
+			ProblemType: ProblemNotFound,
+			Extensions: c.modelExtensions(key, ""),
 		}
-		if err := instance.Save(); err != nil {
+	}
+	// how a patch applies, e.g. whether its test operations pass, would otherwise tell the caller about the model
+	if !c.Authorize(preExisting, "view") || !c.Authorize(preExisting, "modify") {
+		return c.denyAccess("Not authorized to modify this " + c.modelName(), c.modelExtensions(key, "modify"))
+	}
+	if failed := c.checkPrecondition(preExisting, key, "modify"); failed != nil {
+		return *failed
+	}
+	patch, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		return DefaultBadRequestMessage()
+	}
+	instance := c.modelProvider.ModelFactory()
+	switch requestMediaType(c.Request) {
+	case jsonPatchContentType:
+		err = applyJSONPatch(preExisting, instance, patch, c.authenticatedUser)
+	case mergePatchContentType, "application/json", "":
+		err = applyMergePatch(preExisting, instance, patch)
+	default:
+		return unsupportedMediaTypeMessage(requestMediaType(c.Request))
+	}
+	if err != nil {
+		if patchErr, ok := err.(*patchError); ok {
 			return ApiMessage{
-				StatusCode: http.StatusBadRequest,
-				Message: err.Error(),
+				StatusCode: patchErr.StatusCode,
+				Message: patchErr.Message,
+				ProblemType: ProblemInvalidPatch,
 			}
-		} else {
-			if hooker, ok := c.modelProvider.(PUTHooker); ok {
-				if prematureResult := hooker.PostPUTHook(instance, preExisting, c.authenticatedUser, err); prematureResult != nil {
-					return prematureResult
-				}
-			}
-			return HookJsonResult{
-				Body: instance,
+		}
+		return DefaultInternalServerErrorMessage()
+	}
+	if c.formatID(modelKey(instance)) != c.formatID(key) {
+		// everything below would otherwise concern the RESTObject named in the patch, not the one in the URL
+		return ApiMessage{
+			StatusCode: http.StatusUnprocessableEntity,
+			Message: fmt.Sprint("Patch cannot change the ID of ", c.modelName(), " with ID ", c.formatID(key)),
+			ProblemType: ProblemInvalidPatch,
+			Extensions: c.modelExtensions(key, "modify"),
+		}
+	}
+	if missing := c.checkPatchVersion(preExisting, patch, key); missing != nil {
+		return *missing
+	}
+	applyFieldRules(instance, preExisting, c.authenticatedUser)
+	if conflict := c.checkVersion(preExisting, instance, key); conflict != nil {
+		return *conflict
+	}
+	c.linkToParent(instance, parent)
+	if err := CopyImmutableAttributes(preExisting, instance); err != nil {
+		return DefaultInternalServerErrorMessage()
+	}
+	if invalid := validateModel(instance); invalid != nil {
+		return *invalid
+	}
+	if hooker, ok := c.modelProvider.(PATCHHooker); ok {
+		if prematureResult := hooker.PrePATCHHook(instance, preExisting, c.authenticatedUser); prematureResult != nil {
+			return prematureResult
+		}
+	}
+
+	if !c.Authorize(instance, "modify") {
+		return c.denyAccess("Not authorized to modify this " + c.modelName(), c.modelExtensions(key, "modify"))
+	}
+	incrementVersion(instance)
+	if err := instance.Save(); err != nil {
+		return c.persistenceErrorResult(err, key)
+	} else {
+		if hooker, ok := c.modelProvider.(PATCHHooker); ok {
+			if prematureResult := hooker.PostPATCHHook(instance, preExisting, c.authenticatedUser, err); prematureResult != nil {
+				return prematureResult
 			}
 		}
-	})
+		return c.modelResult(instance)
+	}
 }
 
-func (c *GenericRESTController) Delete(id uint64) revel.Result {
+func (c *GenericRESTController) Delete(id interface{}) revel.Result {
 	if !c.modelProvider.EnableDELETE() {
-		return DefaultNotFoundMessage()
+		return c.disabledActionMessage(DefaultNotFoundMessage())
+	}
+	key, errMsg := c.parseID(id)
+	if errMsg != nil {
+		return *errMsg
 	}
-	if found := c.modelProvider.GetModelByID(id); found == nil {
+	if _, prematureResult := c.loadParent(); prematureResult != nil {
+		return prematureResult
+	}
+	return c.destroy(key, nil)
+}
+
+// Deletes the RESTObject identified by key as one item of run, or on its own if run is nil
+func (c *GenericRESTController) destroy(key interface{}, run *bulkRun) revel.Result {
+	found := c.findModel(key)
+	if found == nil {
 		return ApiMessage{
 			StatusCode: http.StatusNotFound,
-			Message: fmt.Sprint(c.modelName(), " with ID ", id, " not found"),
+			Message: fmt.Sprint(c.modelName(), " with ID ", c.formatID(key), " not found"),
+			ProblemType: ProblemNotFound,
+			Extensions: c.modelExtensions(key, ""),
 		}
+	}
+	if run == nil {
+		if failed := c.checkPrecondition(found, key, "delete"); failed != nil {
+			return *failed
+		}
+	}
+	if hooker, ok := c.modelProvider.(DELETEHooker); ok {
+		if prematureResult := hooker.PreDELETEHook(found, c.authenticatedUser); prematureResult != nil {
+			return prematureResult
+		}
+	}
+	if !c.Authorize(found, "delete") {
+		return c.denyAccess("Not authorized to delete this " + c.modelName(), c.modelExtensions(key, "delete"))
+	}
+	if err := run.delete(found); err != nil {
+		return c.persistenceErrorResult(err, key)
 	} else {
 		if hooker, ok := c.modelProvider.(DELETEHooker); ok {
-			if prematureResult := hooker.PreDELETEHook(found, c.authenticatedUser); prematureResult != nil {
+			if prematureResult := run.postHook(func() revel.Result {
+				return hooker.PostDELETEHook(found, c.authenticatedUser, err)
+			}); prematureResult != nil {
 				return prematureResult
 			}
 		}
-		if !found.CanBeDeletedBy(c.authenticatedUser) {
-			return ApiMessage{
-				StatusCode: http.StatusUnauthorized,
-				Message: "Not authorized to delete this " + c.modelName(),
-			}
+		if httpSemanticsEnabled() {
+			return noContentResult{}
 		}
-		if err := found.Delete(); err != nil {
-			return ApiMessage{
-				StatusCode: http.StatusBadRequest,
-				Message: err.Error(),
-			}
-		} else {
-			if hooker, ok := c.modelProvider.(DELETEHooker); ok {
-				if prematureResult := hooker.PostDELETEHook(found, c.authenticatedUser, err); prematureResult != nil {
-					return prematureResult
-				}
-			}
-			return ApiMessage{
-				StatusCode: http.StatusOK,
-				Message: "Success",
-			}
+		return ApiMessage{
+			StatusCode: http.StatusOK,
+			Message: "Success",
 		}
 	}
 }
 
+// Extension members describing the model a problem concerns, rendered in problem details mode
+func (c *GenericRESTController) modelExtensions(key interface{}, action string) map[string]interface{} {
+	extensions := map[string]interface{}{
+		"model": c.modelName(),
+	}
+	if key != nil {
+		extensions["id"] = c.formatID(key)
+	}
+	if action != "" {
+		extensions["action"] = action
+	}
+	return extensions
+}
+
+// The User authenticated for the current request, or nil.
+// Useful for Actions provided by an ActionProvider.
+func (c *GenericRESTController) AuthenticatedUser() User {
+	return c.authenticatedUser
+}
+
 func (c *GenericRESTController) modelName() string {
 	instance := c.modelProvider.ModelFactory()
 	return reflect.TypeOf(instance).Elem().Name()
 }
 
 func (c *GenericRESTController) unmarshalRequestBody(o interface{}, next func() revel.Result) revel.Result {
-	err := json.NewDecoder(c.Request.Body).Decode(o)
+	negotiated, ok := negotiateRequestCodec(c.Request, codecsFor(c.modelProvider))
+	if !ok {
+		return unsupportedMediaTypeMessage(requestMediaType(c.Request))
+	}
+	body, err := ioutil.ReadAll(c.Request.Body)
 	if err != nil {
 		return DefaultBadRequestMessage()
 	}
+	if err := negotiated.codec.Unmarshal(body, o); err != nil {
+		return DefaultBadRequestMessage()
+	}
 	return next()
 }
 
@@ -189,6 +436,7 @@ func DefaultBadRequestMessage() ApiMessage {
 	return ApiMessage{
 		StatusCode: http.StatusBadRequest,
 		Message: "Improperly formatted request body",
+		ProblemType: ProblemBadRequest,
 	}
 }
 
@@ -196,6 +444,7 @@ func DefaultNotFoundMessage() ApiMessage {
 	return ApiMessage{
 		StatusCode: http.StatusNotFound,
 		Message: "Not Found",
+		ProblemType: ProblemNotFound,
 	}
 }
 
@@ -204,5 +453,6 @@ func DefaultInternalServerErrorMessage() ApiMessage {
 	return ApiMessage{
 		StatusCode: http.StatusInternalServerError,
 		Message: revel.Config.StringDefault("apikit.internalservererror", defaultErrMsg),
+		ProblemType: ProblemInternalServerError,
 	}
 }
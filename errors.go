@@ -0,0 +1,130 @@
+package apikit
+
+import (
+	"github.com/revel/revel"
+	"errors"
+	"net/http"
+)
+
+// An error returned from Save or Delete that determines the status code it is reported with.
+// Its message is shown to the client.
+type StatusCoder interface {
+	error
+	StatusCode() int
+}
+
+// Returned from Save or Delete when the RESTObject does not exist (404)
+type ErrNotFound struct {
+	Message string
+}
+
+func (err ErrNotFound) Error() string {
+	return err.Message
+}
+
+func (err ErrNotFound) StatusCode() int {
+	return http.StatusNotFound
+}
+
+// Returned from Save or Delete when the RESTObject conflicts with another, e.g. a duplicate unique key (409)
+type ErrConflict struct {
+	Message string
+}
+
+func (err ErrConflict) Error() string {
+	return err.Message
+}
+
+func (err ErrConflict) StatusCode() int {
+	return http.StatusConflict
+}
+
+// Returned from Save when the RESTObject fails checks that Validate could not make (422).
+// Errors are listed the same way as those found by Validate.
+type ErrValidation struct {
+	Message string
+	Errors  []FieldError
+}
+
+func (err ErrValidation) Error() string {
+	return err.Message
+}
+
+func (err ErrValidation) StatusCode() int {
+	return http.StatusUnprocessableEntity
+}
+
+// Returned from Save or Delete when the authenticated User may not perform the operation (403)
+type ErrForbidden struct {
+	Message string
+}
+
+func (err ErrForbidden) Error() string {
+	return err.Message
+}
+
+func (err ErrForbidden) StatusCode() int {
+	return http.StatusForbidden
+}
+
+// Turns an error returned by Save or Delete into a result.
+// Errors that are not StatusCoders are reported as 500s, and their text is only shown in dev mode.
+func (c *GenericRESTController) persistenceErrorResult(err error, key interface{}) revel.Result {
+	if invalid, ok := validationError(err); ok {
+		msg := ValidationErrorMessage{
+			StatusCode: invalid.StatusCode(),
+			Message: invalid.Message,
+			Errors: invalid.Errors,
+		}
+		if msg.Message == "" {
+			msg.Message = http.StatusText(msg.StatusCode)
+		}
+		return msg
+	}
+
+	var coder StatusCoder
+	if !errors.As(err, &coder) {
+		if !revel.DevMode {
+			return DefaultInternalServerErrorMessage()
+		}
+		return ApiMessage{
+			StatusCode: http.StatusInternalServerError,
+			Message: err.Error(),
+			ProblemType: ProblemInternalServerError,
+		}
+	}
+
+	problemType := ProblemSaveFailed
+	switch coder.StatusCode() {
+	case http.StatusNotFound:
+		problemType = ProblemNotFound
+	case http.StatusConflict:
+		problemType = ProblemConflict
+	case http.StatusForbidden:
+		problemType = ProblemForbidden
+	}
+	// only the typed error's own message, not the context it was wrapped in
+	msg := coder.Error()
+	if msg == "" {
+		msg = http.StatusText(coder.StatusCode())
+	}
+	return ApiMessage{
+		StatusCode: coder.StatusCode(),
+		Message: msg,
+		ProblemType: problemType,
+		Extensions: c.modelExtensions(key, ""),
+	}
+}
+
+// The ErrValidation that err is or wraps, whether by value or by pointer
+func validationError(err error) (*ErrValidation, bool) {
+	var invalid ErrValidation
+	if errors.As(err, &invalid) {
+		return &invalid, true
+	}
+	var invalidPtr *ErrValidation
+	if errors.As(err, &invalidPtr) && invalidPtr != nil {
+		return invalidPtr, true
+	}
+	return nil, false
+}
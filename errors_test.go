@@ -0,0 +1,77 @@
+package apikit
+
+import (
+	"github.com/revel/revel"
+	reveltest "github.com/revel/revel/testing"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+const (
+	// Gadgets with these names fail to Save
+	duplicateGadgetName = "Duplicate"
+	classifiedGadgetName = "Classified"
+	unbalancedGadgetName = "Unbalanced"
+	lopsidedGadgetName = "Lopsided"
+	outageGadgetName = "Outage"
+)
+
+func putGadgetNamed(suite *reveltest.TestSuite, name string) {
+	gadget := gadgetShelf[0]
+	gadget.Name = name
+	body, _ := json.Marshal(&gadget)
+	suite.Put("/gadgets", "application/json", bytes.NewReader(body))
+}
+
+func TestSaveErrorMapping(t *testing.T) {
+	suite := reveltest.NewTestSuite()
+
+	putGadgetNamed(&suite, duplicateGadgetName)
+	suite.AssertStatus(http.StatusConflict)
+	msg := ApiMessage{}
+	err := json.Unmarshal(suite.ResponseBody, &msg)
+	suite.Assert(err == nil)
+	// without the context it was wrapped in
+	suite.AssertEqual("A gadget with that name already exists", msg.Message)
+
+	putGadgetNamed(&suite, classifiedGadgetName)
+	suite.AssertStatus(http.StatusForbidden)
+
+	putGadgetNamed(&suite, unbalancedGadgetName)
+	suite.AssertStatus(http.StatusUnprocessableEntity)
+	invalid := ValidationErrorMessage{}
+	err = json.Unmarshal(suite.ResponseBody, &invalid)
+	suite.Assert(err == nil)
+	suite.AssertEqual(1, len(invalid.Errors))
+	suite.AssertEqual("name", invalid.Errors[0].Key)
+
+	putGadgetNamed(&suite, lopsidedGadgetName)
+	suite.AssertStatus(http.StatusUnprocessableEntity)
+	invalid = ValidationErrorMessage{}
+	err = json.Unmarshal(suite.ResponseBody, &invalid)
+	suite.Assert(err == nil)
+	suite.AssertEqual(1, len(invalid.Errors))
+	suite.AssertEqual("Gadget is lopsided", invalid.Errors[0].Message)
+}
+
+func TestSaveErrorTextHiddenInProduction(t *testing.T) {
+	suite := reveltest.NewTestSuite()
+	suite.Assert(!revel.DevMode)
+
+	putGadgetNamed(&suite, outageGadgetName)
+	suite.AssertStatus(http.StatusInternalServerError)
+	msg := ApiMessage{}
+	err := json.Unmarshal(suite.ResponseBody, &msg)
+	suite.Assert(err == nil)
+	suite.AssertEqual(apiPanicFilterMessage, msg.Message)
+
+	revel.DevMode = true
+	defer func() {
+		revel.DevMode = false
+	}()
+	putGadgetNamed(&suite, outageGadgetName)
+	suite.AssertStatus(http.StatusInternalServerError)
+	suite.AssertContains("connection refused")
+}
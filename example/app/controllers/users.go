@@ -25,6 +25,16 @@ func (c *UserController) GetModelByID(id uint64) apikit.RESTObject {
 	}
 }
 
+// Implementation of ModelLister interface
+func (c *UserController) ListModels(offset, limit int, authUser apikit.User) ([]apikit.RESTObject, int) {
+	users, total := models.ListUsers(offset, limit)
+	objects := make([]apikit.RESTObject, len(users))
+	for i, u := range users {
+		objects[i] = u
+	}
+	return objects, total
+}
+
 func (c *UserController) EnableGET() bool {
 	return true
 }
@@ -40,3 +50,8 @@ func (c *UserController) EnablePUT() bool {
 func (c *UserController) EnableDELETE() bool {
 	return true
 }
+
+// Implementation of PATCHEnabler interface
+func (c *UserController) EnablePATCH() bool {
+	return true
+}
@@ -2,7 +2,6 @@ package models
 import (
 	"github.com/MaxwellPayne/revel-apikit"
 	"github.com/revel/revel"
-	"errors"
 )
 
 // A model that will be provided by a RESTController
@@ -10,7 +9,8 @@ type User struct {
 	ID            uint64    `json:"id"`
 	Username      string    `json:"username"`
 	FavoriteColor string    `json:"favorite_color"`
-	Password      string    `json:"-"`
+	// set by POST and PUT, but never rendered
+	Password      string    `json:"password" apikit:"writeonly"`
 }
 
 // The authentication mechanism for our RESTControllers
@@ -49,7 +49,8 @@ func (u *User) Validate(v *revel.Validation) {
 	if u.ID == 0 {
 		v.Error("0 is not a valid User ID")
 	}
-	v.MinSize(u.Username, 1).Message("Username cannot be blank")
+	v.MinSize(u.Username, 1).Key("username").Message("Username cannot be blank")
+	v.MinSize(u.Password, 6).Key("password").Message("Password must be at least 6 characters")
 }
 
 func (u *User) UniqueID() uint64 {
@@ -66,12 +67,7 @@ func (u *User) Delete() error {
 }
 
 func (u *User) Save() error {
-	v := revel.Validation{}
-	u.Validate(&v)
-	if v.HasErrors() {
-		return errors.New(v.Errors[0].String())
-	}
-
+	// Validate has already been run by the GenericRESTController
 	// not actually persisting data in this example
 	return nil
 }
@@ -87,6 +83,15 @@ func GetUserByID(id uint64) *User {
 	return nil
 }
 
+// Returns at most limit Users starting at offset, along with the total User count
+func ListUsers(offset, limit int) ([]*User, int) {
+	users := []*User{}
+	for i := offset; i < len(usersDB) && i < offset + limit; i++ {
+		users = append(users, usersDB[i])
+	}
+	return users, len(usersDB)
+}
+
 var usersDB []*User = []*User{
 	&User{
 		ID: 1,
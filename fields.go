@@ -0,0 +1,392 @@
+package apikit
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"reflect"
+	"strings"
+)
+
+// Options of the apikit struct tag, which may be combined with commas, e.g. apikit:"readonly,admin"
+const (
+	tagKeyName string = "apikit"
+	// Kept from the existing RESTObject on PUT and PATCH
+	immutableKeyValue string = "immutable"
+	// Same as immutable: accepted on POST only
+	createOnlyKeyValue string = "createonly"
+	// Ignored on input, always rendered
+	readOnlyKeyValue string = "readonly"
+	// Accepted on input, never rendered. Left alone on PUT and PATCH when not given.
+	writeOnlyKeyValue string = "writeonly"
+	// Only rendered for and accepted from Users with admin privileges
+	adminKeyValue string = "admin"
+	// An integer that PUT and PATCH must name and match, incremented each time the RESTObject is saved.
+	// Save must enforce it too, since other requests may save between the check and Save.
+	versionKeyValue string = "version"
+)
+
+func hasTagOption(field reflect.StructField, option string) bool {
+	for _, opt := range strings.Split(field.Tag.Get(tagKeyName), ",") {
+		if strings.TrimSpace(opt) == option {
+			return true
+		}
+	}
+	return false
+}
+
+// Whether a field is kept from the existing RESTObject on PUT and PATCH
+func isImmutableField(field reflect.StructField) bool {
+	return hasTagOption(field, immutableKeyValue) || hasTagOption(field, createOnlyKeyValue)
+}
+
+// Whether a field is left out when rendering for a User
+func isHiddenField(field reflect.StructField, admin bool) bool {
+	return hasTagOption(field, writeOnlyKeyValue) || (!admin && hasTagOption(field, adminKeyValue))
+}
+
+// The name a field is encoded under by encoding/json, or false if it is not encoded
+func jsonFieldName(field reflect.StructField) (string, bool) {
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if name == "-" && field.Tag.Get("json") == "-" {
+		return "", false
+	}
+	if name == "" {
+		name = field.Name
+	}
+	return name, true
+}
+
+// The field of a struct type that encoding/json decodes the member name into, looking into embedded structs
+func jsonMemberField(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		fieldName, encoded := jsonFieldName(field)
+		if field.Anonymous && indirectType(field.Type).Kind() == reflect.Struct && field.Tag.Get("json") == "" {
+			if promoted, ok := jsonMemberField(indirectType(field.Type), name); ok {
+				return promoted, true
+			}
+			continue
+		}
+		if field.PkgPath != "" || !encoded {
+			continue
+		}
+		if strings.EqualFold(fieldName, name) {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// Whether the tokens of a JSON pointer into a document encoded from t reach a field hidden from the User,
+// or a value that holds one at any depth
+func pointerReachesHiddenField(t reflect.Type, tokens []string, admin bool) bool {
+	for _, token := range tokens {
+		t = indirectType(t)
+		switch t.Kind() {
+		case reflect.Struct:
+			field, ok := jsonMemberField(t, token)
+			if !ok {
+				return false
+			}
+			if isHiddenField(field, admin) {
+				return true
+			}
+			t = field.Type
+		case reflect.Slice, reflect.Array, reflect.Map:
+			t = t.Elem()
+		default:
+			return false
+		}
+	}
+	return holdsHiddenField(t, admin, map[reflect.Type]bool{})
+}
+
+func holdsHiddenField(t reflect.Type, admin bool, seen map[reflect.Type]bool) bool {
+	t = indirectType(t)
+	if seen[t] {
+		return false
+	}
+	seen[t] = true
+	switch t.Kind() {
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if _, encoded := jsonFieldName(field); !encoded || (field.PkgPath != "" && !field.Anonymous) {
+				continue
+			}
+			if isHiddenField(field, admin) || holdsHiddenField(field.Type, admin, seen) {
+				return true
+			}
+		}
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return holdsHiddenField(t.Elem(), admin, seen)
+	}
+	return false
+}
+
+func isAdmin(user User) bool {
+	return user != nil && user.HasAdminPrivileges()
+}
+
+// Undoes the changes a request made to fields it may not set.
+// existing is nil for POST, in which case those fields are reset to their zero value.
+func applyFieldRules(instance, existing interface{}, user User) {
+	vNew := reflect.ValueOf(instance)
+	if vNew.Kind() != reflect.Ptr || vNew.Elem().Kind() != reflect.Struct {
+		return
+	}
+	var vOld reflect.Value
+	if existing != nil {
+		if vOld = reflect.ValueOf(existing); vOld.Kind() != reflect.Ptr || vOld.Elem().Type() != vNew.Elem().Type() {
+			return
+		}
+		vOld = vOld.Elem()
+	}
+	applyStructFieldRules(vNew.Elem(), vOld, isAdmin(user))
+}
+
+func applyStructFieldRules(vNew, vOld reflect.Value, admin bool) {
+	for i := 0; i < vNew.NumField(); i++ {
+		field := vNew.Type().Field(i)
+		newField := vNew.Field(i)
+		var oldField reflect.Value
+		if vOld.IsValid() {
+			oldField = vOld.Field(i)
+		}
+		if !newField.CanSet() {
+			// the exported fields of embedded structs can be set even if the struct is unexported
+			if field.Anonymous && field.Type.Kind() == reflect.Struct {
+				applyStructFieldRules(newField, oldField, admin)
+			}
+			continue
+		}
+
+		restore := hasTagOption(field, readOnlyKeyValue) ||
+			(!admin && hasTagOption(field, adminKeyValue)) ||
+			(oldField.IsValid() && hasTagOption(field, createOnlyKeyValue)) ||
+			(!oldField.IsValid() && hasTagOption(field, versionKeyValue)) ||
+			(oldField.IsValid() && hasTagOption(field, writeOnlyKeyValue) && newField.IsZero())
+		if restore {
+			if oldField.IsValid() {
+				newField.Set(oldField)
+			} else {
+				newField.Set(reflect.Zero(field.Type))
+			}
+		} else if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			applyStructFieldRules(newField, oldField, admin)
+		}
+	}
+}
+
+// Wraps the Body of a HookJsonResult so that fields hidden from the User are not rendered
+func redactBody(body interface{}, user User) interface{} {
+	admin := isAdmin(user)
+	if body == nil || !hasHiddenValues(reflect.ValueOf(body), admin) {
+		return body
+	}
+	return redactedBody{body: body, admin: admin}
+}
+
+type redactedBody struct {
+	body  interface{}
+	admin bool
+}
+
+func (r redactedBody) document() (interface{}, error) {
+	data, err := json.Marshal(r.body)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := decodeJSONDocument(data)
+	if err != nil {
+		return nil, err
+	}
+	redactDocument(reflect.ValueOf(r.body), doc, r.admin)
+	return doc, nil
+}
+
+func (r redactedBody) MarshalJSON() ([]byte, error) {
+	doc, err := r.document()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(doc)
+}
+
+// encoding/xml cannot leave fields out, so hidden fields are emptied on a copy of the body instead
+func (r redactedBody) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	redacted := redactedCopy(reflect.ValueOf(r.body), r.admin).Interface()
+	if start.Name.Local == reflect.TypeOf(r).Name() {
+		// this is the root element, so name it after the body as if it were encoded directly
+		return e.Encode(redacted)
+	}
+	return e.EncodeElement(redacted, start)
+}
+
+// Deep copies v, emptying the fields hidden from the User
+func redactedCopy(v reflect.Value, admin bool) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		copied := reflect.New(v.Type().Elem())
+		copied.Elem().Set(redactedCopy(v.Elem(), admin))
+		return copied
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		copied := reflect.New(v.Type()).Elem()
+		copied.Set(redactedCopy(v.Elem(), admin))
+		return copied
+	case reflect.Struct:
+		copied := reflect.New(v.Type()).Elem()
+		copied.Set(v)
+		redactStructInPlace(copied, admin)
+		return copied
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		copied := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			copied.Index(i).Set(redactedCopy(v.Index(i), admin))
+		}
+		return copied
+	case reflect.Array:
+		copied := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			copied.Index(i).Set(redactedCopy(v.Index(i), admin))
+		}
+		return copied
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		copied := reflect.MakeMapWithSize(v.Type(), v.Len())
+		for _, key := range v.MapKeys() {
+			copied.SetMapIndex(key, redactedCopy(v.MapIndex(key), admin))
+		}
+		return copied
+	}
+	return v
+}
+
+func redactStructInPlace(v reflect.Value, admin bool) {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		fieldVal := v.Field(i)
+		if !fieldVal.CanSet() {
+			if field.Anonymous && field.Type.Kind() == reflect.Struct {
+				redactStructInPlace(fieldVal, admin)
+			}
+		} else if isHiddenField(field, admin) {
+			fieldVal.Set(reflect.Zero(field.Type))
+		} else {
+			fieldVal.Set(redactedCopy(fieldVal, admin))
+		}
+	}
+}
+
+// Whether v holds a struct with fields hidden from the User, looking through pointers, interfaces and collections
+func hasHiddenValues(v reflect.Value, admin bool) bool {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return false
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if field.PkgPath != "" && !field.Anonymous {
+				continue
+			}
+			if _, encoded := jsonFieldName(field); !encoded {
+				continue
+			}
+			if isHiddenField(field, admin) || hasHiddenValues(v.Field(i), admin) {
+				return true
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if hasHiddenValues(v.Index(i), admin) {
+				return true
+			}
+		}
+	case reflect.Map:
+		for _, key := range v.MapKeys() {
+			if hasHiddenValues(v.MapIndex(key), admin) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Removes the fields hidden from the User from doc, the decoded JSON document of v
+func redactDocument(v reflect.Value, doc interface{}, admin bool) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		if members, ok := doc.(map[string]interface{}); ok {
+			redactStruct(v, members, admin)
+		}
+	case reflect.Slice, reflect.Array:
+		if items, ok := doc.([]interface{}); ok && len(items) == v.Len() {
+			for i, item := range items {
+				redactDocument(v.Index(i), item, admin)
+			}
+		}
+	case reflect.Map:
+		if members, ok := doc.(map[string]interface{}); ok && v.Type().Key().Kind() == reflect.String {
+			for _, key := range v.MapKeys() {
+				redactDocument(v.MapIndex(key), members[key.String()], admin)
+			}
+		}
+	}
+}
+
+func redactStruct(v reflect.Value, members map[string]interface{}, admin bool) {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+		name, encoded := jsonFieldName(field)
+		if !encoded {
+			continue
+		}
+		if field.Anonymous && strings.Split(field.Tag.Get("json"), ",")[0] == "" {
+			// the embedded struct's fields are promoted into this one
+			embedded := v.Field(i)
+			for embedded.Kind() == reflect.Ptr && !embedded.IsNil() {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				redactStruct(embedded, members, admin)
+				continue
+			}
+		}
+		if isHiddenField(field, admin) {
+			delete(members, name)
+		} else {
+			redactDocument(v.Field(i), members[name], admin)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package apikit
+
+import (
+	"github.com/revel/revel"
+	reveltest "github.com/revel/revel/testing"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type fieldRulesBase struct {
+	Serial string `json:"serial" apikit:"createonly"`
+}
+
+type fieldRulesModel struct {
+	fieldRulesBase
+	ID        uint64 `json:"id" apikit:"readonly"`
+	Secret    string `json:"secret" apikit:"writeonly"`
+	Clearance int    `json:"clearance" apikit:"admin"`
+	Audit     string `json:"audit" apikit:"readonly,admin"`
+	Note      string `json:"note"`
+}
+
+var lastPostedUser *ExampleUser
+
+// POSTHooker interface implementation, to see what was decoded from POST requests
+func (c *ExampleUserController) PrePOSTHook(model RESTObject, authUser User) revel.Result {
+	lastPostedUser = model.(*ExampleUser)
+	return nil
+}
+
+func (c *ExampleUserController) PostPOSTHook(model RESTObject, authUser User, err error) revel.Result {
+	return nil
+}
+
+func TestApplyFieldRulesOnCreate(t *testing.T) {
+	posted := fieldRulesModel{
+		fieldRulesBase: fieldRulesBase{Serial: "S-1"},
+		ID: 4,
+		Secret: "hunter2",
+		Clearance: 9,
+		Audit: "forged",
+		Note: "hi",
+	}
+	applyFieldRules(&posted, nil, usersDB[0])
+	expected := fieldRulesModel{fieldRulesBase: fieldRulesBase{Serial: "S-1"}, Secret: "hunter2", Note: "hi"}
+	if posted != expected {
+		t.Error("Expected", expected, "got", posted)
+	}
+
+	posted = fieldRulesModel{Clearance: 9, Audit: "forged"}
+	applyFieldRules(&posted, nil, usersDB[2])
+	if posted.Clearance != 9 || posted.Audit != "" {
+		t.Error("Admins may set admin fields but not readonly ones, got", posted)
+	}
+}
+
+func TestApplyFieldRulesOnUpdate(t *testing.T) {
+	existing := fieldRulesModel{
+		fieldRulesBase: fieldRulesBase{Serial: "S-1"},
+		ID: 4,
+		Secret: "hunter2",
+		Clearance: 3,
+		Audit: "created by admin",
+	}
+	updated := fieldRulesModel{
+		fieldRulesBase: fieldRulesBase{Serial: "S-2"},
+		ID: 5,
+		Clearance: 9,
+		Note: "changed",
+	}
+	applyFieldRules(&updated, &existing, usersDB[0])
+	expected := existing
+	expected.Note = "changed"
+	if updated != expected {
+		t.Error("Expected", expected, "got", updated)
+	}
+
+	// writeonly fields can be changed, just not erased by leaving them out
+	updated = existing
+	updated.Secret = "correct horse"
+	applyFieldRules(&updated, &existing, usersDB[2])
+	if updated.Secret != "correct horse" {
+		t.Error("writeonly field was not updated")
+	}
+}
+
+func TestRedactBody(t *testing.T) {
+	model := &fieldRulesModel{
+		fieldRulesBase: fieldRulesBase{Serial: "S-1"},
+		ID: 4,
+		Secret: "hunter2",
+		Clearance: 3,
+		Audit: "created by admin",
+	}
+	for _, example := range []struct {
+		user   User
+		absent []string
+	}{
+		{nil, []string{"secret", "clearance", "audit"}},
+		{usersDB[0], []string{"secret", "clearance", "audit"}},
+		{usersDB[2], []string{"secret"}},
+	} {
+		data, err := json.Marshal(redactBody(&ModelPage{Items: []interface{}{usersDB[0]}, Total: 1}, example.user))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(data), usersDB[0].Password) {
+			t.Error("writeonly field was rendered in", string(data))
+		}
+
+		data, err = json.Marshal(redactBody(model, example.user))
+		if err != nil {
+			t.Fatal(err)
+		}
+		members := map[string]interface{}{}
+		json.Unmarshal(data, &members)
+		for _, name := range example.absent {
+			if _, ok := members[name]; ok {
+				t.Error(name, "was rendered for", example.user, "in", string(data))
+			}
+		}
+		if members["serial"] != "S-1" || members["id"] != float64(4) {
+			t.Error("visible fields were not rendered in", string(data))
+		}
+	}
+}
+
+func TestRenderFieldRules(t *testing.T) {
+	me, admin := usersDB[0], usersDB[2]
+	endpoint := fmt.Sprint("/user/", me.ID)
+	suite := reveltest.NewTestSuite()
+
+	suite.Get(endpoint)
+	suite.AssertOk()
+	suite.AssertNotContains(me.Password)
+	suite.AssertNotContains("is_admin")
+
+	requestAs(&suite, admin, "GET", endpoint, nil)
+	suite.AssertOk()
+	suite.AssertNotContains(me.Password)
+	suite.AssertContains(`"is_admin":false`)
+
+	// XML cannot leave fields out, but it empties them
+	req := suite.GetCustom(apiKeysUrl(endpoint))
+	req.Header.Set("Accept", "application/xml")
+	req.MakeRequest()
+	suite.AssertOk()
+	suite.AssertContains("<Username>" + me.Username + "</Username>")
+	suite.AssertNotContains(me.Password)
+
+	// nested models are redacted too
+	suite.Get(fmt.Sprint("/fish/", pond[0].ID))
+	suite.AssertOk()
+	suite.AssertNotContains(pond[0].Owner.Password)
+}
+
+func TestPostFieldRules(t *testing.T) {
+	me := usersDB[0]
+	suite := reveltest.NewTestSuite()
+
+	body := []byte(fmt.Sprintf(`{"id": %d, "username": "%s", "password": "new password", "is_admin": true}`, me.ID, me.Username))
+	requestAs(&suite, me, "POST", "/user", body)
+	suite.AssertOk()
+	suite.AssertNotContains("new password")
+	// writeonly fields can now be set through POST
+	suite.AssertEqual("new password", lastPostedUser.Password)
+	// but only admins may make admins
+	suite.Assert(!lastPostedUser.IsAdmin)
+}
+
+func TestPointerReachesHiddenField(t *testing.T) {
+	examples := []struct {
+		model  interface{}
+		tokens []string
+		admin  bool
+		hidden bool
+	}{
+		{&fieldRulesModel{}, []string{"secret"}, false, true},
+		{&fieldRulesModel{}, []string{"Secret"}, true, true},
+		{&fieldRulesModel{}, []string{"clearance"}, false, true},
+		{&fieldRulesModel{}, []string{"clearance"}, true, false},
+		{&fieldRulesModel{}, []string{"note"}, false, false},
+		// promoted from the embedded struct
+		{&fieldRulesModel{}, []string{"serial"}, false, false},
+		{&Fish{}, []string{"owner", "password"}, false, true},
+		// the owner holds a password, so it cannot be copied or tested as a whole
+		{&Fish{}, []string{"owner"}, false, true},
+		{&Fish{}, []string{"owner", "username"}, false, false},
+		{&Fish{}, []string{"tags", "0"}, false, false},
+		{&[]fieldRulesModel{}, []string{"0", "secret"}, false, true},
+	}
+	for _, example := range examples {
+		if hidden := pointerReachesHiddenField(reflect.TypeOf(example.model), example.tokens, example.admin); hidden != example.hidden {
+			t.Error("Expected", example.tokens, "of", reflect.TypeOf(example.model), "to be hidden:", example.hidden)
+		}
+	}
+}
@@ -8,7 +8,13 @@ import (
 
 type AuthenticationFunction func(username, password string) User
 
+// Injects into RESTControllers a User authenticated with HTTP Basic credentials
 func CreateRESTControllerInjectionFilter(authFunction AuthenticationFunction) revel.Filter {
+	return CreateRESTControllerAuthenticatorFilter(BasicAuthenticator(authFunction))
+}
+
+// Injects into RESTControllers the User identified by the first of the authenticators to recognize the request
+func CreateRESTControllerAuthenticatorFilter(authenticators ...Authenticator) revel.Filter {
 	return func(c *revel.Controller, fc []revel.Filter) {
 		if embedsRESTController(c.AppController) {
 			// use the RESTController only if this controller embeds one
@@ -21,18 +27,64 @@ func CreateRESTControllerInjectionFilter(authFunction AuthenticationFunction) re
 			restController := getEmbeddedRESTController(c.AppController)
 			restController.modelProvider = ctrlAsModelProvider
 
-			if username, pass, ok := c.Request.BasicAuth(); ok {
-				restController.authenticatedUser = authFunction(username, pass)
-			}
+			restController.authenticators = authenticators
+			authUser, authErr := authenticate(c.Request, authenticators)
+			restController.authenticatedUser = authUser
 			restController.Request = c.Request
+			restController.routeParams = c.Params.Route
 
 			setEmbeddedRESTController(c.AppController, *restController)
+
+			codecs := codecsFor(ctrlAsModelProvider)
+			negotiated, ok := negotiateResponseCodec(c.Request, codecs)
+			if !ok {
+				c.Result = notAcceptableMessage(codecs)
+				return
+			}
+			if authErr != nil {
+				c.Result = restController.authenticationErrorMessage(authErr).withCodec(negotiated)
+				return
+			}
+			if denied := restController.checkAccessPolicy(c.MethodName); denied != nil {
+				c.Result = denied.withCodec(negotiated)
+				return
+			}
+
+			fc[0](c, fc[1:]) // Execute the next filter stage.
+			c.Result = finishResult(c.Result, authUser, negotiated)
+			return
+		}
+
+		if receiver, ok := c.AppController.(AuthenticatedUserReceiver); ok {
+			authUser, authErr := authenticate(c.Request, authenticators)
+			if authErr != nil {
+				challenger := GenericRESTController{authenticators: authenticators}
+				c.Result = challenger.authenticationErrorMessage(authErr)
+				return
+			}
+			receiver.SetAuthenticatedUser(authUser)
 		}
 
 		fc[0](c, fc[1:]) // Execute the next filter stage.
 	}
 }
 
+// Redacts and encodes the result of an Action for the authenticated User,
+// including one whose response is being recorded for an Idempotency-Key
+func finishResult(result revel.Result, authUser User, negotiated registeredCodec) revel.Result {
+	switch r := result.(type) {
+	case idempotentResult:
+		r.result = finishResult(r.result, authUser, negotiated)
+		return r
+	case HookJsonResult:
+		r.Body = redactBody(r.Body, authUser)
+		return r.withCodec(negotiated)
+	case encodedResult:
+		return r.withCodec(negotiated)
+	}
+	return result
+}
+
 func APIPanicFilter(c *revel.Controller, fc []revel.Filter) {
 	defer func() {
 		if err := recover(); err != nil {
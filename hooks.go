@@ -1,16 +1,24 @@
 package apikit
 import (
 	"github.com/revel/revel"
-	"encoding/json"
 	"net/http"
 )
 
+// PreGETHook is only called for RESTControllers whose RESTObjects are identified by a uint64
 type GETHooker interface {
 	RESTController
 	PreGETHook(id uint64, authUser User) revel.Result
 	PostGETHook(model RESTObject, authUser User) revel.Result
 }
 
+// A GETHooker for RESTControllers identified by any kind of key, such as those that are IdentifiedBy.
+// PreGETHookKey is passed the key parsed by the controller's IDCodec, and is called in place of PreGETHook.
+type KeyedGETHooker interface {
+	RESTController
+	PreGETHookKey(key interface{}, authUser User) revel.Result
+	PostGETHook(model RESTObject, authUser User) revel.Result
+}
+
 type POSTHooker interface {
 	RESTController
 	PrePOSTHook(model RESTObject, authUser User) revel.Result
@@ -23,6 +31,12 @@ type PUTHooker interface {
 	PostPUTHook(newInstance, existingInstance RESTObject, authUser User, err error) revel.Result
 }
 
+type PATCHHooker interface {
+	RESTController
+	PrePATCHHook(newInstance, existingInstance RESTObject, authUser User) revel.Result
+	PostPATCHHook(newInstance, existingInstance RESTObject, authUser User, err error) revel.Result
+}
+
 type DELETEHooker interface {
 	RESTController
 	PreDELETEHook(model RESTObject, authUser User) revel.Result
@@ -30,18 +44,36 @@ type DELETEHooker interface {
 }
 
 // Result that can be returned from a hook
+// Despite its name, the Body is encoded with whichever codec was negotiated for the request
 type HookJsonResult struct {
 	Body interface{}
+	// Added to the response, e.g. ETag
+	Headers http.Header
+	// 200 OK if not set
+	StatusCode int
+
+	codec *registeredCodec
 }
 
 func (result HookJsonResult) Apply(req *revel.Request, resp *revel.Response) {
-	if body, err := json.Marshal(result.Body); err != nil {
+	for name, values := range result.Headers {
+		resp.Out.Header()[name] = values
+	}
+	statusCode := result.StatusCode
+	if statusCode == 0 {
+		statusCode = http.StatusOK
+	}
+	if err := writeEncoded(req, resp, statusCode, result.codec, result.Body); err != nil {
 		ApiMessage{
 			StatusCode: http.StatusInternalServerError,
 			Message: "Something went wrong",
+			ProblemType: ProblemInternalServerError,
+			codec: result.codec,
 		}.Apply(req, resp)
-	} else {
-		resp.WriteHeader(http.StatusOK, "application/json")
-		resp.Out.Write(body)
 	}
+}
+
+func (result HookJsonResult) withCodec(negotiated registeredCodec) revel.Result {
+	result.codec = &negotiated
+	return result
 }
\ No newline at end of file
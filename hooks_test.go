@@ -21,6 +21,7 @@ type Fish struct {
 	Color      string       `json:"color"`
 	IsImmortal bool         `json:"is_immortal"`
 	Owner      *ExampleUser `json:"owner"`
+	Tags       []string     `json:"tags"`
 }
 
 func (fish *Fish) CanBeViewedBy(user User) bool {
@@ -44,7 +45,7 @@ func (fish *Fish) UniqueID() uint64 {
 }
 
 func (fish *Fish) Validate(v *revel.Validation) {
-	v.Min(fish.FinCount, 2).Message("Fish must have at least 2 fins")
+	v.Min(fish.FinCount, 2).Key("fin_count").Message("Fish must have at least 2 fins")
 }
 
 func (fish *Fish) Delete() error {
@@ -68,6 +69,9 @@ const (
 	// Use these constants for DELETEHooker tests
 	fishDeleteFailureMessage = "Foolish mortal, you cannot kill an immortal fish."
 	fishDeleteSuccessMessage = "Uh oh, owner. Looks like you killed your own fish."
+
+	// Use this constant for PATCHHooker tests
+	fishImmortalityMessage = "Immortality cannot be patched in."
 )
 
 var (
@@ -114,6 +118,10 @@ func (c *FishHookerController) EnableDELETE() bool {
 	return true
 }
 
+func (c *FishHookerController) EnablePATCH() bool {
+	return true
+}
+
 // GETHooker interface implementation
 func (c *FishHookerController) PreGETHook(id uint64, authUser User) revel.Result {
 	if id == luckyFishID {
@@ -164,6 +172,21 @@ func (c *FishHookerController) PostPUTHook(newInstance, existingInstance RESTObj
 	return nil
 }
 
+// PATCHHooker interface implementation
+func (c *FishHookerController) PrePATCHHook(newInstance, existingInstance RESTObject, authUser User) revel.Result {
+	if newInstance.(*Fish).IsImmortal && !existingInstance.(*Fish).IsImmortal {
+		return ApiMessage{
+			StatusCode: http.StatusForbidden,
+			Message: fishImmortalityMessage,
+		}
+	}
+	return nil
+}
+
+func (c *FishHookerController) PostPATCHHook(newInstance, existingInstance RESTObject, authUser User, err error) revel.Result {
+	return nil
+}
+
 // DELETEHooker interface implementation
 func (c *FishHookerController) PreDELETEHook(model RESTObject, authUser User) revel.Result {
 	fish := model.(*Fish)
@@ -194,6 +217,7 @@ var pond []Fish = []Fish{
 		IsImmortal: false,
 		Owner: usersDB[0],
 		CreateDate: time.Now(),
+		Tags: []string{"small", "fast"},
 	},
 	Fish{
 		ID: 8,
@@ -283,7 +307,7 @@ func TestPostPOSTHook(t *testing.T) {
 	}
 	body, _ := json.Marshal(&invalidFish)
 	suite.Post(endpoint, "application/json", bytes.NewReader(body))
-	suite.AssertStatus(http.StatusBadRequest)
+	suite.AssertStatus(http.StatusUnprocessableEntity)
 
 	wg := sync.WaitGroup{}
 	wg.Add(1)
@@ -336,7 +360,7 @@ func TestPostPUTHook(t *testing.T) {
 	fish.FinCount = 1
 	body, _ := json.Marshal(&fish)
 	suite.Post(endpoint, "application/json", bytes.NewReader(body))
-	suite.AssertStatus(http.StatusBadRequest)
+	suite.AssertStatus(http.StatusUnprocessableEntity)
 
 	wg := sync.WaitGroup{}
 	wg.Add(1)
@@ -0,0 +1,265 @@
+package apikit
+
+import (
+	"github.com/revel/revel"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	idempotencyKeyHeader     string        = "Idempotency-Key"
+	idempotentReplayedHeader string        = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  int           = 255
+	defaultIdempotencyTTL    time.Duration = 24 * time.Hour
+	// Long enough for any request to be handled
+	defaultInProgressIdempotencyTTL time.Duration = time.Minute
+)
+
+// The response to a POST sent with an Idempotency-Key, kept to be replayed to retries of the same request
+type IdempotencyRecord struct {
+	Key string
+	// Who sent the request: the type of its User and the key it is recorded by, see idempotencyScope
+	Scope string
+	// A hash of the request's path and body
+	Fingerprint string
+	Created     time.Time
+	// Whether the response below has been recorded, or the request is still being handled.
+	// Claims that are not completed within apikit.idempotency.inprogressttl are abandoned, e.g. after a crash.
+	Complete   bool
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// Where Idempotency-Keys and the responses to their requests are kept, until they are older than apikit.idempotency.ttl
+type IdempotencyStore interface {
+	// Claims the key of record within its scope, returning the record that claimed it earlier instead, if any
+	ClaimIdempotencyKey(record *IdempotencyRecord) (*IdempotencyRecord, error)
+	// Saves the response to the request that claimed the key
+	CompleteIdempotencyKey(record *IdempotencyRecord) error
+	// Forgets a key whose request failed, so that it can be retried
+	ReleaseIdempotencyKey(record *IdempotencyRecord) error
+}
+
+// The store used by GenericRESTController.Post, in memory unless replaced
+var IdempotencyKeys IdempotencyStore = NewMemoryIdempotencyStore()
+
+// How long Idempotency-Keys are kept, set by apikit.idempotency.ttl in app.conf
+func idempotencyTTL() time.Duration {
+	if ttl, err := time.ParseDuration(revel.Config.StringDefault("apikit.idempotency.ttl", "")); err == nil {
+		return ttl
+	}
+	return defaultIdempotencyTTL
+}
+
+// How long a key is claimed by a request that has not been answered, set by apikit.idempotency.inprogressttl
+func inProgressIdempotencyTTL() time.Duration {
+	if ttl, err := time.ParseDuration(revel.Config.StringDefault("apikit.idempotency.inprogressttl", "")); err == nil {
+		return ttl
+	}
+	return defaultInProgressIdempotencyTTL
+}
+
+// Whether a record should be forgotten, either because it is older than the TTL, or because the request that
+// claimed it has not been answered in time
+func (record *IdempotencyRecord) expired(now time.Time) bool {
+	if !record.Complete && record.Created.Before(now.Add(-inProgressIdempotencyTTL())) {
+		return true
+	}
+	return record.Created.Before(now.Add(-idempotencyTTL()))
+}
+
+// An IdempotencyStore that forgets its keys when the app exits
+type MemoryIdempotencyStore struct {
+	mutex   sync.Mutex
+	records map[string]IdempotencyRecord
+}
+
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{records: map[string]IdempotencyRecord{}}
+}
+
+func (record *IdempotencyRecord) storeKey() string {
+	return record.Scope + "\x00" + record.Key
+}
+
+func (store *MemoryIdempotencyStore) ClaimIdempotencyKey(record *IdempotencyRecord) (*IdempotencyRecord, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	now := time.Now()
+	for key, existing := range store.records {
+		if existing.expired(now) {
+			delete(store.records, key)
+		}
+	}
+	if existing, ok := store.records[record.storeKey()]; ok {
+		return &existing, nil
+	}
+	store.records[record.storeKey()] = *record
+	return nil, nil
+}
+
+func (store *MemoryIdempotencyStore) CompleteIdempotencyKey(record *IdempotencyRecord) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.records[record.storeKey()] = *record
+	return nil
+}
+
+func (store *MemoryIdempotencyStore) ReleaseIdempotencyKey(record *IdempotencyRecord) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	delete(store.records, record.storeKey())
+	return nil
+}
+
+// Handles a request with next unless the authenticated User has sent its Idempotency-Key before,
+// in which case the response to that request is replayed
+func (c *GenericRESTController) idempotent(next func() revel.Result) revel.Result {
+	key := c.Request.Header.Get(idempotencyKeyHeader)
+	if key == "" {
+		return next()
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		return ApiMessage{
+			StatusCode: http.StatusBadRequest,
+			Message: fmt.Sprint(idempotencyKeyHeader, " may be at most ", maxIdempotencyKeyLength, " characters"),
+			ProblemType: ProblemBadRequest,
+		}
+	}
+	if c.authenticatedUser == nil {
+		// anonymous clients cannot be told apart, so they would replay one another's responses
+		return c.denyAccess(idempotencyKeyHeader + " may only be sent by authenticated Users", nil)
+	}
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		return DefaultBadRequestMessage()
+	}
+	c.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	scope := idempotencyScope(c.authenticatedUser)
+	fingerprint := sha256.New()
+	fingerprint.Write([]byte(c.Request.Method + " " + c.Request.URL.Path + "\n"))
+	fingerprint.Write(body)
+	record := &IdempotencyRecord{
+		Key: key,
+		Scope: scope,
+		Fingerprint: hex.EncodeToString(fingerprint.Sum(nil)),
+		Created: time.Now(),
+	}
+
+	existing, err := IdempotencyKeys.ClaimIdempotencyKey(record)
+	if err != nil {
+		return c.persistenceErrorResult(err, nil)
+	}
+	if existing != nil {
+		extensions := map[string]interface{}{
+			"idempotency_key": key,
+		}
+		if existing.Fingerprint != record.Fingerprint {
+			return ApiMessage{
+				StatusCode: http.StatusUnprocessableEntity,
+				Message: idempotencyKeyHeader + " " + strconv.Quote(key) + " was already used for a different request",
+				ProblemType: ProblemIdempotencyKeyReused,
+				Extensions: extensions,
+			}
+		}
+		if !existing.Complete {
+			return ApiMessage{
+				StatusCode: http.StatusConflict,
+				Message: "The request with " + idempotencyKeyHeader + " " + strconv.Quote(key) + " is still being handled",
+				ProblemType: ProblemConflict,
+				Extensions: extensions,
+			}
+		}
+		return replayedResult{record: existing}
+	}
+
+	defer func() {
+		if err := recover(); err != nil {
+			IdempotencyKeys.ReleaseIdempotencyKey(record)
+			panic(err)
+		}
+	}()
+	return idempotentResult{result: next(), record: record}
+}
+
+// Records the response to a request with an Idempotency-Key as it is written
+type idempotentResult struct {
+	result revel.Result
+	record *IdempotencyRecord
+}
+
+func (r idempotentResult) Apply(req *revel.Request, resp *revel.Response) {
+	out := resp.Out
+	recorder := &responseRecorder{ResponseWriter: out, statusCode: http.StatusOK}
+	resp.Out = recorder
+	r.result.Apply(req, resp)
+	resp.Out = out
+
+	if recorder.statusCode >= http.StatusInternalServerError {
+		// let the request be retried
+		if err := IdempotencyKeys.ReleaseIdempotencyKey(r.record); err != nil {
+			revel.WARN.Println("Could not release", idempotencyKeyHeader, strconv.Quote(r.record.Key), err)
+		}
+		return
+	}
+	r.record.Complete = true
+	r.record.StatusCode = recorder.statusCode
+	r.record.Header = http.Header{}
+	for name, values := range out.Header() {
+		if name == "Set-Cookie" {
+			// cookies belong to the response they were set on, e.g. a session that a retry should not share
+			continue
+		}
+		r.record.Header[name] = append([]string(nil), values...)
+	}
+	r.record.Body = recorder.body.Bytes()
+	if err := IdempotencyKeys.CompleteIdempotencyKey(r.record); err != nil {
+		revel.WARN.Println("Could not record the response to", idempotencyKeyHeader, strconv.Quote(r.record.Key), err)
+	}
+}
+
+type responseRecorder struct {
+	http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(statusCode int) {
+	r.statusCode = statusCode
+	r.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	r.body.Write(data)
+	return r.ResponseWriter.Write(data)
+}
+
+// Replays the recorded response to an earlier request with the same Idempotency-Key
+type replayedResult struct {
+	record *IdempotencyRecord
+}
+
+func (r replayedResult) Apply(req *revel.Request, resp *revel.Response) {
+	for name, values := range r.record.Header {
+		resp.Out.Header()[name] = values
+	}
+	resp.Out.Header().Set(idempotentReplayedHeader, "true")
+	resp.Status = r.record.StatusCode
+	resp.Out.WriteHeader(r.record.StatusCode)
+	resp.Out.Write(r.record.Body)
+}
+
+// Users of different types may share keys, and keyed Users may all share a UniqueID, so both go into the scope
+func idempotencyScope(user User) string {
+	return reflect.TypeOf(user).String() + ":" + userKey(user)
+}
@@ -0,0 +1,113 @@
+package apikit
+
+import (
+	reveltest "github.com/revel/revel/testing"
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func postNoteWithKey(suite *reveltest.TestSuite, key, body string, user *ExampleUser) {
+	req := suite.PostCustom(suite.BaseUrl() + "/notes", "application/json", bytes.NewBufferString(body))
+	req.Header.Set(idempotencyKeyHeader, key)
+	if user != nil {
+		req.SetBasicAuth(user.Username, user.Password)
+	}
+	req.MakeRequest()
+}
+
+func TestIdempotencyKey(t *testing.T) {
+	suite := reveltest.NewTestSuite()
+	const key = "6b1d4a0e-retry"
+	viewer := usersDB[1]
+	stored := len(notebook)
+
+	postNoteWithKey(&suite, key, `{"text":"Buy fish food"}`, viewer)
+	suite.AssertOk()
+	suite.AssertHeader(idempotentReplayedHeader, "")
+	created := decodeNote(&suite)
+
+	// a retry gets the same response without creating another Note
+	postNoteWithKey(&suite, key, `{"text":"Buy fish food"}`, viewer)
+	suite.AssertOk()
+	suite.AssertHeader(idempotentReplayedHeader, "true")
+	suite.AssertHeader("ETag", `W/"1"`)
+	suite.AssertEqual(created, decodeNote(&suite))
+	suite.AssertEqual(stored + 1, len(notebook))
+
+	postNoteWithKey(&suite, key, `{"text":"Buy more fish food"}`, viewer)
+	suite.AssertStatus(http.StatusUnprocessableEntity)
+	suite.AssertContains("different request")
+
+	// keys are only shared by requests of the same User
+	postNoteWithKey(&suite, key, `{"text":"Buy fish food"}`, usersDB[0])
+	suite.AssertOk()
+	suite.AssertHeader(idempotentReplayedHeader, "")
+	suite.AssertNotEqual(created.ID, decodeNote(&suite).ID)
+
+	// failures are replayed too
+	postNoteWithKey(&suite, "invalid-note", `{"text":5}`, viewer)
+	suite.AssertStatus(http.StatusBadRequest)
+	postNoteWithKey(&suite, "invalid-note", `{"text":5}`, viewer)
+	suite.AssertStatus(http.StatusBadRequest)
+	suite.AssertHeader(idempotentReplayedHeader, "true")
+
+	postNoteWithKey(&suite, strings.Repeat("k", maxIdempotencyKeyLength + 1), `{"text":"Too long"}`, viewer)
+	suite.AssertStatus(http.StatusBadRequest)
+
+	// anonymous clients would all share one scope
+	postNoteWithKey(&suite, "anonymous-retry", `{"text":"Buy fish food"}`, nil)
+	suite.AssertStatus(http.StatusUnauthorized)
+	suite.AssertEqual(stored + 2, len(notebook))
+}
+
+func TestIdempotencyKeyInProgress(t *testing.T) {
+	suite := reveltest.NewTestSuite()
+	viewer := usersDB[1]
+	record := &IdempotencyRecord{
+		Key: "in-progress",
+		Scope: idempotencyScope(viewer),
+	}
+	body := `{"text":"Feed the fish"}`
+	postNoteWithKey(&suite, record.Key, body, viewer)
+	suite.AssertOk()
+	existing, _ := IdempotencyKeys.ClaimIdempotencyKey(record)
+	suite.Assert(existing != nil && existing.Complete)
+	suite.AssertEqual("", existing.Header.Get("Set-Cookie"))
+
+	// as if the first request had not been answered yet
+	existing.Complete = false
+	IdempotencyKeys.CompleteIdempotencyKey(existing)
+	postNoteWithKey(&suite, record.Key, body, viewer)
+	suite.AssertStatus(http.StatusConflict)
+
+	// nor will it be, if it has been handled for longer than apikit.idempotency.inprogressttl
+	existing.Created = time.Now().Add(-2 * defaultInProgressIdempotencyTTL)
+	IdempotencyKeys.CompleteIdempotencyKey(existing)
+	postNoteWithKey(&suite, record.Key, body, viewer)
+	suite.AssertOk()
+	suite.AssertHeader(idempotentReplayedHeader, "")
+
+	existing, _ = IdempotencyKeys.ClaimIdempotencyKey(record)
+	IdempotencyKeys.ReleaseIdempotencyKey(existing)
+	postNoteWithKey(&suite, record.Key, body, viewer)
+	suite.AssertOk()
+	suite.AssertHeader(idempotentReplayedHeader, "")
+}
+
+func TestIdempotencyScope(t *testing.T) {
+	alice := &uuidUser{UUID: "3f2504e0-4f89-11d3-9a0c-0305e82c3301"}
+	bob := &uuidUser{UUID: "6ba7b810-9dad-11d1-80b4-00c04fd430c8"}
+	if idempotencyScope(alice) == idempotencyScope(bob) {
+		t.Error("keyed Users with the same UniqueID should not share a scope")
+	}
+	if idempotencyScope(alice) != idempotencyScope(&uuidUser{UUID: alice.UUID}) {
+		t.Error("a User should always get the same scope")
+	}
+	numbered := &ExampleUser{ID: 7}
+	if idempotencyScope(numbered) == idempotencyScope(&uuidUser{UUID: "7"}) {
+		t.Error("Users of different types should not share a scope")
+	}
+}
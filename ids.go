@@ -0,0 +1,173 @@
+package apikit
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Converts between the :id route parameter and the identifier a RESTController looks up its RESTObjects by.
+// The parameter is always bound as a string and parsed by the codec.
+type IDCodec interface {
+	ParseID(raw string) (interface{}, error)
+	FormatID(id interface{}) string
+}
+
+// A RESTController whose RESTObjects are identified by something other than a uint64,
+// such as a string, UUID or composite key.
+// GetModelByKey is used in place of GetModelByID, which is never called for these controllers.
+type IdentifiedBy interface {
+	RESTController
+	IDCodec() IDCodec
+	GetModelByKey(key interface{}) RESTObject
+}
+
+// A RESTObject whose identifier is not a uint64.
+// ModelKey must return values of the type produced by its controller's IDCodec.
+type KeyedRESTObject interface {
+	RESTObject
+	ModelKey() interface{}
+}
+
+// The default IDCodec, used for RESTControllers that are not IdentifiedBy
+type Uint64IDCodec struct{}
+
+func (codec Uint64IDCodec) ParseID(raw string) (interface{}, error) {
+	return strconv.ParseUint(raw, 10, 64)
+}
+
+func (codec Uint64IDCodec) FormatID(id interface{}) string {
+	return fmt.Sprint(id)
+}
+
+// Identifies RESTObjects by arbitrary non-empty strings, e.g. slugs
+type StringIDCodec struct{}
+
+func (codec StringIDCodec) ParseID(raw string) (interface{}, error) {
+	if raw == "" {
+		return nil, errors.New("ID cannot be blank")
+	}
+	return raw, nil
+}
+
+func (codec StringIDCodec) FormatID(id interface{}) string {
+	return fmt.Sprint(id)
+}
+
+var uuidPattern *regexp.Regexp = regexp.MustCompile(
+	"^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$")
+
+// Identifies RESTObjects by UUIDs in their canonical textual form.
+// Parsed IDs are lowercased strings.
+type UUIDIDCodec struct{}
+
+func (codec UUIDIDCodec) ParseID(raw string) (interface{}, error) {
+	uuid := strings.ToLower(raw)
+	if !uuidPattern.MatchString(uuid) {
+		return nil, errors.New("ID is not a valid UUID")
+	}
+	return uuid, nil
+}
+
+func (codec UUIDIDCodec) FormatID(id interface{}) string {
+	return strings.ToLower(fmt.Sprint(id))
+}
+
+// Identifies RESTObjects by multi-part keys joined by Separator, e.g. /memberships/12:34.
+// Parsed IDs are []interface{} holding one value per Part.
+type CompositeIDCodec struct {
+	Separator string
+	Parts     []IDCodec
+}
+
+func (codec CompositeIDCodec) ParseID(raw string) (interface{}, error) {
+	rawParts := strings.Split(raw, codec.Separator)
+	if len(rawParts) != len(codec.Parts) {
+		return nil, fmt.Errorf("ID must have %d parts separated by %q", len(codec.Parts), codec.Separator)
+	}
+	key := make([]interface{}, len(codec.Parts))
+	for i, part := range codec.Parts {
+		parsed, err := part.ParseID(rawParts[i])
+		if err != nil {
+			return nil, fmt.Errorf("Part %d of ID: %s", i + 1, err)
+		}
+		key[i] = parsed
+	}
+	return key, nil
+}
+
+func (codec CompositeIDCodec) FormatID(id interface{}) string {
+	parts, ok := id.([]interface{})
+	if !ok || len(parts) != len(codec.Parts) {
+		return fmt.Sprint(id)
+	}
+	formatted := make([]string, len(parts))
+	for i, part := range parts {
+		formatted[i] = codec.Parts[i].FormatID(part)
+	}
+	return strings.Join(formatted, codec.Separator)
+}
+
+func idCodecFor(c RESTController) IDCodec {
+	if keyed, ok := c.(IdentifiedBy); ok {
+		return keyed.IDCodec()
+	}
+	return Uint64IDCodec{}
+}
+
+// Turns an id argument bound by Revel into a key that the modelProvider understands
+func (c *GenericRESTController) parseID(id interface{}) (interface{}, *ApiMessage) {
+	codec := idCodecFor(c.modelProvider)
+	raw, ok := id.(string)
+	if !ok {
+		// e.g. an Action of an ActionProvider passing on a key it has already parsed
+		raw = codec.FormatID(id)
+	}
+	key, err := codec.ParseID(raw)
+	if err != nil {
+		return nil, &ApiMessage{
+			StatusCode: http.StatusBadRequest,
+			Message: fmt.Sprint("Invalid ", c.modelName(), " ID ", strconv.Quote(raw), ": ", err.Error()),
+			ProblemType: ProblemInvalidID,
+			Extensions: map[string]interface{}{
+				"model": c.modelName(),
+				"id": raw,
+			},
+		}
+	}
+	return key, nil
+}
+
+func (c *GenericRESTController) formatID(key interface{}) string {
+	return idCodecFor(c.modelProvider).FormatID(key)
+}
+
+func (c *GenericRESTController) findModel(key interface{}) RESTObject {
+	if scoped, ok := c.modelProvider.(ParentScopedController); ok {
+		return scoped.GetScopedModelByID(c.parentIDs, key)
+	}
+	if keyed, ok := c.modelProvider.(IdentifiedBy); ok {
+		return keyed.GetModelByKey(key)
+	}
+	id, ok := key.(uint64)
+	if !ok {
+		return nil
+	}
+	return c.modelProvider.GetModelByID(id)
+}
+
+func modelKey(model RESTObject) interface{} {
+	if keyed, ok := model.(KeyedRESTObject); ok {
+		return keyed.ModelKey()
+	}
+	return model.UniqueID()
+}
+
+// How a User is recorded wherever it has to be told apart from the others, e.g. as the owner of an API key:
+// its ModelKey if it is a KeyedRESTObject, whose UniqueID may well be 0 for every User, and its UniqueID otherwise.
+func userKey(user User) string {
+	return fmt.Sprint(modelKey(user))
+}
@@ -0,0 +1,230 @@
+package apikit
+
+import (
+	"github.com/revel/revel"
+	reveltest "github.com/revel/revel/testing"
+	"testing"
+	"encoding/json"
+	"bytes"
+	"net/http"
+	"strings"
+	"errors"
+	"fmt"
+)
+
+// A model identified by a UUID rather than a uint64
+type Gadget struct {
+	UUID string `json:"uuid"`
+	Name string `json:"name"`
+}
+
+func (g *Gadget) CanBeViewedBy(user User) bool {
+	return true
+}
+
+func (g *Gadget) CanBeCreatedBy(user User) bool {
+	return true
+}
+
+func (g *Gadget) CanBeModifiedBy(user User) bool {
+	return true
+}
+
+func (g *Gadget) CanBeDeletedBy(user User) bool {
+	return true
+}
+
+func (g *Gadget) Validate(v *revel.Validation) {
+	v.Required(g.Name).Key("name").Message("Gadgets must be named")
+}
+
+func (g *Gadget) UniqueID() uint64 {
+	return 0
+}
+
+func (g *Gadget) ModelKey() interface{} {
+	return g.UUID
+}
+
+func (g *Gadget) Delete() error {
+	return nil
+}
+
+// Some names make Save fail, for the error mapping tests in errors_test.go
+func (g *Gadget) Save() error {
+	switch g.Name {
+	case duplicateGadgetName:
+		return fmt.Errorf("saving gadget: %w", ErrConflict{Message: "A gadget with that name already exists"})
+	case classifiedGadgetName:
+		return ErrForbidden{Message: "Classified gadgets cannot be changed"}
+	case unbalancedGadgetName:
+		return ErrValidation{
+			Errors: []FieldError{
+				{Key: "name", Message: "Gadget is unbalanced"},
+			},
+		}
+	case lopsidedGadgetName:
+		return fmt.Errorf("saving gadget: %w", &ErrValidation{
+			Errors: []FieldError{
+				{Key: "name", Message: "Gadget is lopsided"},
+			},
+		})
+	case outageGadgetName:
+		return errors.New("dial tcp 10.0.0.7:5432: connection refused")
+	}
+	return nil
+}
+
+type GadgetController struct {
+	*revel.Controller
+	GenericRESTController
+}
+
+func (c *GadgetController) ModelFactory() RESTObject {
+	return &Gadget{}
+}
+
+func (c *GadgetController) GetModelByID(id uint64) RESTObject {
+	return nil
+}
+
+// IdentifiedBy interface implementation
+func (c *GadgetController) IDCodec() IDCodec {
+	return UUIDIDCodec{}
+}
+
+func (c *GadgetController) GetModelByKey(key interface{}) RESTObject {
+	for _, g := range gadgetShelf {
+		if g.UUID == key.(string) {
+			found := g
+			return &found
+		}
+	}
+	return nil
+}
+
+// KeyedGETHooker interface implementation
+func (c *GadgetController) PreGETHookKey(key interface{}, authUser User) revel.Result {
+	if key == recalledGadgetUUID {
+		return ApiMessage{
+			StatusCode: http.StatusGone,
+			Message: "This gadget has been recalled",
+		}
+	}
+	return nil
+}
+
+func (c *GadgetController) PostGETHook(model RESTObject, authUser User) revel.Result {
+	return nil
+}
+
+func (c *GadgetController) EnableGET() bool {
+	return true
+}
+
+func (c *GadgetController) EnablePOST() bool {
+	return true
+}
+
+func (c *GadgetController) EnablePUT() bool {
+	return true
+}
+
+func (c *GadgetController) EnableDELETE() bool {
+	return true
+}
+
+// Answered by PreGETHookKey whether or not it is on the shelf
+const recalledGadgetUUID = "0badc0de-0000-4000-8000-000000000000"
+
+var gadgetShelf []Gadget = []Gadget{
+	Gadget{
+		UUID: "3f2504e0-4f89-11d3-9a0c-0305e82c3301",
+		Name: "Sprocket",
+	},
+}
+
+func TestGetGadgetByUUID(t *testing.T) {
+	gadget := gadgetShelf[0]
+	suite := reveltest.NewTestSuite()
+
+	// UUIDs are normalized before lookup
+	suite.Get("/gadgets/" + strings.ToUpper(gadget.UUID))
+	suite.AssertOk()
+	found := Gadget{}
+	err := json.Unmarshal(suite.ResponseBody, &found)
+	suite.Assert(err == nil)
+	suite.AssertEqual(gadget.Name, found.Name)
+
+	suite.Get("/gadgets/not-a-uuid")
+	suite.AssertStatus(http.StatusBadRequest)
+	suite.AssertContains("not-a-uuid")
+
+	missing := "00000000-0000-0000-0000-000000000000"
+	suite.Get("/gadgets/" + missing)
+	suite.AssertStatus(http.StatusNotFound)
+	suite.AssertContains(missing)
+
+	// PreGETHookKey is passed the parsed key
+	suite.Get("/gadgets/" + strings.ToUpper(recalledGadgetUUID))
+	suite.AssertStatus(http.StatusGone)
+}
+
+func TestMalformedUint64ID(t *testing.T) {
+	suite := reveltest.NewTestSuite()
+	for _, id := range []string{"banana", "-1", "18446744073709551616"} {
+		suite.Get("/user/" + id)
+		suite.AssertStatus(http.StatusBadRequest)
+		suite.AssertContains(id)
+	}
+}
+
+func TestPutGadgetByUUID(t *testing.T) {
+	gadget := gadgetShelf[0]
+	gadget.Name = "Cog"
+	body, _ := json.Marshal(&gadget)
+
+	suite := reveltest.NewTestSuite()
+	suite.Put("/gadgets", "application/json", bytes.NewReader(body))
+	suite.AssertOk()
+	suite.AssertContains("Cog")
+
+	gadget.UUID = "00000000-0000-0000-0000-000000000000"
+	body, _ = json.Marshal(&gadget)
+	suite.Put("/gadgets", "application/json", bytes.NewReader(body))
+	suite.AssertStatus(http.StatusNotFound)
+}
+
+func TestDeleteGadgetByUUID(t *testing.T) {
+	suite := reveltest.NewTestSuite()
+	suite.Delete("/gadgets/" + gadgetShelf[0].UUID)
+	suite.AssertOk()
+
+	suite.Delete("/gadgets/12345")
+	suite.AssertStatus(http.StatusBadRequest)
+}
+
+func TestCompositeIDCodec(t *testing.T) {
+	codec := CompositeIDCodec{
+		Separator: ":",
+		Parts: []IDCodec{Uint64IDCodec{}, StringIDCodec{}},
+	}
+
+	key, err := codec.ParseID("12:nemo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := key.([]interface{})
+	if parts[0] != uint64(12) || parts[1] != "nemo" {
+		t.Error("Composite ID parsed incorrectly:", parts)
+	}
+	if formatted := codec.FormatID(key); formatted != "12:nemo" {
+		t.Error("Composite ID formatted incorrectly:", formatted)
+	}
+
+	for _, raw := range []string{"12", "12:nemo:extra", "twelve:nemo", "12:"} {
+		if _, err := codec.ParseID(raw); err == nil {
+			t.Error("Composite ID", raw, "should not have parsed")
+		}
+	}
+}
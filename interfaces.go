@@ -30,6 +30,27 @@ type RESTController interface {
 	EnableDELETE() bool
 }
 
+// A RESTController that serves Actions beyond those provided by GenericRESTController.
+// Arg types are given as pointers, as with revel.RegisterController.
+type ActionProvider interface {
+	RESTController
+	Actions() []*revel.MethodType
+}
+
+// A RESTController that opts in to partial updates through PATCH requests
+type PATCHEnabler interface {
+	RESTController
+	EnablePATCH() bool
+}
+
+// A RESTController that can serve its RESTObjects as a paginated collection.
+// ListModels returns at most limit models starting at offset, along with the
+// total number of models in the collection.
+type ModelLister interface {
+	RESTController
+	ListModels(offset, limit int, authUser User) ([]RESTObject, int)
+}
+
 // A RESTObject that can be authenticated by RESTControllers
 type User interface {
 	RESTObject
@@ -0,0 +1,465 @@
+package apikit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const (
+	jsonPatchContentType string = "application/json-patch+json"
+)
+
+// An error encountered while applying a patch, along with the status code it should be reported with
+type patchError struct {
+	StatusCode int
+	Message    string
+}
+
+func (err *patchError) Error() string {
+	return err.Message
+}
+
+func malformedPatch(format string, args ...interface{}) *patchError {
+	return &patchError{
+		StatusCode: http.StatusBadRequest,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+func conflictingPatch(format string, args ...interface{}) *patchError {
+	return &patchError{
+		StatusCode: http.StatusConflict,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// A single RFC 6902 JSON Patch operation
+type jsonPatchOperation struct {
+	Op    string           `json:"op"`
+	Path  string           `json:"path"`
+	From  string           `json:"from"`
+	Value *json.RawMessage `json:"value"`
+
+	path, from []string
+	value      interface{}
+}
+
+// Decodes JSON while keeping numbers intact, so that large IDs survive the round trip
+func decodeJSONDocument(data []byte) (interface{}, error) {
+	var doc interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// Splits an RFC 6901 JSON Pointer into its unescaped reference tokens
+func parseJSONPointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return []string{}, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, malformedPatch("JSON Pointer %q must start with /", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1)
+	}
+	return tokens, nil
+}
+
+func parseJSONPatch(patch []byte) ([]*jsonPatchOperation, error) {
+	var ops []*jsonPatchOperation
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return nil, malformedPatch("JSON Patch must be an array of operations")
+	}
+
+	for i, op := range ops {
+		var err error
+		if op.path, err = parseJSONPointer(op.Path); err != nil {
+			return nil, err
+		}
+		switch op.Op {
+		case "add", "replace", "test":
+			if op.Value == nil {
+				return nil, malformedPatch("Operation %d (%s) is missing a value", i, op.Op)
+			}
+			if op.value, err = decodeJSONDocument(*op.Value); err != nil {
+				return nil, malformedPatch("Operation %d (%s) has an invalid value", i, op.Op)
+			}
+		case "move", "copy":
+			if op.from, err = parseJSONPointer(op.From); err != nil {
+				return nil, err
+			}
+		case "remove":
+		default:
+			return nil, malformedPatch("Operation %d has unknown op %q", i, op.Op)
+		}
+	}
+	return ops, nil
+}
+
+// Parses an array index token. The "-" token refers to the end of the array when allowed.
+func arrayIndex(token string, length int, allowEnd bool) (int, error) {
+	if token == "-" && allowEnd {
+		return length, nil
+	}
+	idx, err := strconv.Atoi(token)
+	if err != nil || idx < 0 || (token != "0" && strings.HasPrefix(token, "0")) {
+		return 0, conflictingPatch("%q is not a valid array index", token)
+	}
+	max := length - 1
+	if allowEnd {
+		max = length
+	}
+	if idx > max {
+		return 0, conflictingPatch("Array index %d is out of bounds", idx)
+	}
+	return idx, nil
+}
+
+// Walks doc down to the parent of the last token and lets edit replace that parent.
+// Returns the (possibly replaced) document.
+func editAtPointer(doc interface{}, tokens []string, edit func(parent interface{}, token string) (interface{}, error)) (interface{}, error) {
+	if len(tokens) == 1 {
+		return edit(doc, tokens[0])
+	}
+	switch node := doc.(type) {
+	case map[string]interface{}:
+		child, ok := node[tokens[0]]
+		if !ok {
+			return nil, conflictingPatch("Path member %q does not exist", tokens[0])
+		}
+		newChild, err := editAtPointer(child, tokens[1:], edit)
+		if err != nil {
+			return nil, err
+		}
+		node[tokens[0]] = newChild
+		return node, nil
+	case []interface{}:
+		idx, err := arrayIndex(tokens[0], len(node), false)
+		if err != nil {
+			return nil, err
+		}
+		newChild, err := editAtPointer(node[idx], tokens[1:], edit)
+		if err != nil {
+			return nil, err
+		}
+		node[idx] = newChild
+		return node, nil
+	default:
+		return nil, conflictingPatch("Path member %q does not exist", tokens[0])
+	}
+}
+
+func getAtPointer(doc interface{}, tokens []string) (interface{}, error) {
+	for _, token := range tokens {
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			child, ok := node[token]
+			if !ok {
+				return nil, conflictingPatch("Path member %q does not exist", token)
+			}
+			doc = child
+		case []interface{}:
+			idx, err := arrayIndex(token, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			doc = node[idx]
+		default:
+			return nil, conflictingPatch("Path member %q does not exist", token)
+		}
+	}
+	return doc, nil
+}
+
+func addAtPointer(doc interface{}, tokens []string, value interface{}) (interface{}, error) {
+	if len(tokens) == 0 {
+		return value, nil
+	}
+	return editAtPointer(doc, tokens, func(parent interface{}, token string) (interface{}, error) {
+		switch node := parent.(type) {
+		case map[string]interface{}:
+			node[token] = value
+			return node, nil
+		case []interface{}:
+			idx, err := arrayIndex(token, len(node), true)
+			if err != nil {
+				return nil, err
+			}
+			node = append(node, nil)
+			copy(node[idx+1:], node[idx:])
+			node[idx] = value
+			return node, nil
+		default:
+			return nil, conflictingPatch("Cannot add member %q to a non-container value", token)
+		}
+	})
+}
+
+func removeAtPointer(doc interface{}, tokens []string) (interface{}, error) {
+	if len(tokens) == 0 {
+		return nil, conflictingPatch("Cannot remove the whole document")
+	}
+	return editAtPointer(doc, tokens, func(parent interface{}, token string) (interface{}, error) {
+		switch node := parent.(type) {
+		case map[string]interface{}:
+			if _, ok := node[token]; !ok {
+				return nil, conflictingPatch("Path member %q does not exist", token)
+			}
+			delete(node, token)
+			return node, nil
+		case []interface{}:
+			idx, err := arrayIndex(token, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			return append(node[:idx], node[idx+1:]...), nil
+		default:
+			return nil, conflictingPatch("Path member %q does not exist", token)
+		}
+	})
+}
+
+func deepCopyJSON(value interface{}) interface{} {
+	switch node := value.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(node))
+		for k, v := range node {
+			copied[k] = deepCopyJSON(v)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(node))
+		for i, v := range node {
+			copied[i] = deepCopyJSON(v)
+		}
+		return copied
+	default:
+		return value
+	}
+}
+
+// Compares two decoded JSON values, treating numbers by value rather than by spelling
+func jsonValuesEqual(a, b interface{}) bool {
+	aNum, aIsNum := a.(json.Number)
+	bNum, bIsNum := b.(json.Number)
+	if aIsNum && bIsNum {
+		if aNum == bNum {
+			return true
+		}
+		af, aErr := aNum.Float64()
+		bf, bErr := bNum.Float64()
+		if aErr == nil && bErr == nil {
+			return af == bf
+		}
+		return false
+	}
+	switch aNode := a.(type) {
+	case map[string]interface{}:
+		bNode, ok := b.(map[string]interface{})
+		if !ok || len(aNode) != len(bNode) {
+			return false
+		}
+		for k, v := range aNode {
+			if other, ok := bNode[k]; !ok || !jsonValuesEqual(v, other) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		bNode, ok := b.([]interface{})
+		if !ok || len(aNode) != len(bNode) {
+			return false
+		}
+		for i := range aNode {
+			if !jsonValuesEqual(aNode[i], bNode[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return reflect.DeepEqual(a, b)
+	}
+}
+
+func isPointerPrefix(prefix, tokens []string) bool {
+	if len(prefix) >= len(tokens) {
+		return false
+	}
+	for i := range prefix {
+		if prefix[i] != tokens[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Applies every operation to doc in order. The first failing operation aborts the whole patch.
+func applyJSONPatchOperations(doc interface{}, ops []*jsonPatchOperation) (interface{}, error) {
+	for i, op := range ops {
+		var err error
+		switch op.Op {
+		case "add":
+			doc, err = addAtPointer(doc, op.path, op.value)
+		case "remove":
+			doc, err = removeAtPointer(doc, op.path)
+		case "replace":
+			if _, err = getAtPointer(doc, op.path); err == nil {
+				if len(op.path) == 0 {
+					doc = op.value
+				} else if doc, err = removeAtPointer(doc, op.path); err == nil {
+					doc, err = addAtPointer(doc, op.path, op.value)
+				}
+			}
+		case "move":
+			if isPointerPrefix(op.from, op.path) {
+				return nil, malformedPatch("Operation %d cannot move a value into one of its children", i)
+			}
+			var value interface{}
+			if value, err = getAtPointer(doc, op.from); err == nil {
+				if doc, err = removeAtPointer(doc, op.from); err == nil {
+					doc, err = addAtPointer(doc, op.path, value)
+				}
+			}
+		case "copy":
+			var value interface{}
+			if value, err = getAtPointer(doc, op.from); err == nil {
+				doc, err = addAtPointer(doc, op.path, deepCopyJSON(value))
+			}
+		case "test":
+			var value interface{}
+			if value, err = getAtPointer(doc, op.path); err == nil && !jsonValuesEqual(value, op.value) {
+				err = conflictingPatch("Test operation %d failed: value at %q did not match", i, op.Path)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return doc, nil
+}
+
+// Produces a new instance of the existing RESTObject with the RFC 6902 JSON Patch applied to it.
+// Patches that touch immutable attributes or the ID, or that read or write fields hidden from user, are rejected,
+// and nothing is applied unless every operation succeeds.
+func applyJSONPatch(existing, instance RESTObject, patch []byte, user User) error {
+	ops, err := parseJSONPatch(patch)
+	if err != nil {
+		return err
+	}
+
+	existingData, err := json.Marshal(existing)
+	if err != nil {
+		return err
+	}
+	doc, err := decodeJSONDocument(existingData)
+	if err != nil {
+		return err
+	}
+
+	modelType := reflect.TypeOf(existing)
+	modelName := modelType.Elem().Name()
+	admin := isAdmin(user)
+	immutable := immutableAttributeNames(existing)
+	identifiers := identifierAttributeNames(existing, doc)
+	for i, op := range ops {
+		// every pointer the operation reads or writes, so that hidden values can be neither copied out,
+		// guessed by test, nor overwritten
+		pointers := [][]string{op.path}
+		touched := [][]string{}
+		switch op.Op {
+		case "add", "remove", "replace":
+			touched = append(touched, op.path)
+		case "move":
+			touched = append(touched, op.path, op.from)
+			pointers = append(pointers, op.from)
+		case "copy":
+			touched = append(touched, op.path)
+			pointers = append(pointers, op.from)
+		}
+		for _, tokens := range pointers {
+			if len(tokens) == 0 {
+				// the whole document holds every attribute, immutable, identifying and hidden alike
+				return &patchError{
+					StatusCode: http.StatusUnprocessableEntity,
+					Message: fmt.Sprintf("Operation %d applies to the whole %s; patch its attributes instead", i, modelName),
+				}
+			}
+			if pointerReachesHiddenField(modelType, tokens, admin) {
+				return &patchError{
+					StatusCode: http.StatusUnprocessableEntity,
+					Message: fmt.Sprintf("Operation %d reaches an attribute of the %s that cannot be patched", i, modelName),
+				}
+			}
+		}
+		for _, tokens := range touched {
+			attribute := strings.ToLower(tokens[0])
+			if immutable[attribute] {
+				return &patchError{
+					StatusCode: http.StatusUnprocessableEntity,
+					Message: fmt.Sprintf("Operation %d modifies immutable attribute %q", i, tokens[0]),
+				}
+			}
+			if identifiers[attribute] {
+				return &patchError{
+					StatusCode: http.StatusUnprocessableEntity,
+					Message: fmt.Sprintf("Operation %d modifies attribute %q, which identifies the %s", i, tokens[0],
+						modelName),
+				}
+			}
+		}
+	}
+
+	if doc, err = applyJSONPatchOperations(doc, ops); err != nil {
+		return err
+	}
+
+	patchedData, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(patchedData, instance); err != nil {
+		return malformedPatch("Patched %s could not be decoded: %s", reflect.TypeOf(instance).Elem().Name(), err)
+	}
+	return copyUnserializedAttributes(existing, instance)
+}
+
+// The lowercased top-level attributes of doc, the JSON document of existing, that its ID is decoded from.
+// Each attribute is left out of the document in turn to see whether the decoded ID changes.
+func identifierAttributeNames(existing RESTObject, doc interface{}) map[string]bool {
+	names := map[string]bool{}
+	obj, ok := doc.(map[string]interface{})
+	if !ok {
+		return names
+	}
+	key := modelKey(existing)
+	for name := range obj {
+		without := map[string]interface{}{}
+		for other, value := range obj {
+			if other != name {
+				without[other] = value
+			}
+		}
+		data, err := json.Marshal(without)
+		if err != nil {
+			continue
+		}
+		probe, ok := reflect.New(reflect.TypeOf(existing).Elem()).Interface().(RESTObject)
+		if !ok || json.Unmarshal(data, probe) != nil || copyUnserializedAttributes(existing, probe) != nil {
+			continue
+		}
+		if !reflect.DeepEqual(modelKey(probe), key) {
+			names[strings.ToLower(name)] = true
+		}
+	}
+	return names
+}
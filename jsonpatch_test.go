@@ -0,0 +1,266 @@
+package apikit
+
+import (
+	"testing"
+	"github.com/revel/revel"
+	reveltest "github.com/revel/revel/testing"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// A model with a writeonly attribute, which only its owner may modify
+type Locker struct {
+	ID          uint64 `json:"id"`
+	Label       string `json:"label"`
+	Combination string `json:"combination" apikit:"writeonly"`
+	OwnerID     uint64 `json:"owner_id"`
+}
+
+var lockerRoom = map[uint64]Locker{
+	1: {ID: 1, Label: "Fishing rods", Combination: "1234", OwnerID: 1},
+}
+
+func (l *Locker) CanBeViewedBy(user User) bool {
+	return true
+}
+
+func (l *Locker) CanBeCreatedBy(user User) bool {
+	return false
+}
+
+func (l *Locker) CanBeModifiedBy(user User) bool {
+	return user != nil && user.UniqueID() == l.OwnerID
+}
+
+func (l *Locker) CanBeDeletedBy(user User) bool {
+	return false
+}
+
+func (l *Locker) UniqueID() uint64 {
+	return l.ID
+}
+
+func (l *Locker) Validate(v *revel.Validation) {
+
+}
+
+func (l *Locker) Save() error {
+	lockerRoom[l.ID] = *l
+	return nil
+}
+
+func (l *Locker) Delete() error {
+	return nil
+}
+
+type LockerController struct {
+	*revel.Controller
+	GenericRESTController
+}
+
+func (c *LockerController) ModelFactory() RESTObject {
+	return &Locker{}
+}
+
+func (c *LockerController) GetModelByID(id uint64) RESTObject {
+	if locker, ok := lockerRoom[id]; ok {
+		return &locker
+	}
+	return nil
+}
+
+func (c *LockerController) EnableGET() bool {
+	return true
+}
+
+func (c *LockerController) EnablePOST() bool {
+	return false
+}
+
+func (c *LockerController) EnablePUT() bool {
+	return false
+}
+
+func (c *LockerController) EnableDELETE() bool {
+	return false
+}
+
+func (c *LockerController) EnablePATCH() bool {
+	return true
+}
+
+func patchLockerAs(suite *reveltest.TestSuite, user *ExampleUser, patch string) {
+	req := suite.PatchCustom(suite.BaseUrl() + "/lockers/1", jsonPatchContentType, strings.NewReader(patch))
+	if user != nil {
+		req.SetBasicAuth(user.Username, user.Password)
+	}
+	req.MakeRequest()
+}
+
+func TestApplyJSONPatchOperations(t *testing.T) {
+	// examples from RFC 6902, Appendix A
+	examples := []struct {
+		original, patch, result string
+		conflict                bool
+	}{
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"baz":"qux","foo":"bar"}`, false},
+		{`{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`, false},
+		{`{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, `{"foo":"bar"}`, false},
+		{`{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`, `{"foo":["bar","baz"]}`, false},
+		{`{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"boo"}]`, `{"baz":"boo","foo":"bar"}`, false},
+		{`{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`,
+			`[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			`{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`, false},
+		{`{"foo":["all","grass","cows","eat"]}`, `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`,
+			`{"foo":["all","cows","eat","grass"]}`, false},
+		{`{"baz":"qux","foo":["a",2,"c"]}`,
+			`[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2}]`,
+			`{"baz":"qux","foo":["a",2,"c"]}`, false},
+		{`{"baz":"qux"}`, `[{"op":"test","path":"/baz","value":"bar"}]`, ``, true},
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/child","value":{"grandchild":{}}}]`,
+			`{"foo":"bar","child":{"grandchild":{}}}`, false},
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz/bat","value":"qux"}]`, ``, true},
+		{`{"/":9,"~1":10}`, `[{"op":"test","path":"/~01","value":10}]`, `{"/":9,"~1":10}`, false},
+		{`{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`, `{"foo":["bar",["abc","def"]]}`, false},
+		{`{"foo":["bar"]}`, `[{"op":"copy","from":"/foo/0","path":"/baz"}]`, `{"foo":["bar"],"baz":"bar"}`, false},
+		{`{"foo":["bar"]}`, `[{"op":"remove","path":"/foo/5"}]`, ``, true},
+	}
+	for _, example := range examples {
+		doc, _ := decodeJSONDocument([]byte(example.original))
+		ops, err := parseJSONPatch([]byte(example.patch))
+		if err != nil {
+			t.Error("Could not parse", example.patch, err)
+			continue
+		}
+
+		result, err := applyJSONPatchOperations(doc, ops)
+		if example.conflict {
+			if patchErr, ok := err.(*patchError); !ok || patchErr.StatusCode != http.StatusConflict {
+				t.Error("Applying", example.patch, "to", example.original, "should have conflicted, got", err)
+			}
+			continue
+		}
+		expected, _ := decodeJSONDocument([]byte(example.result))
+		if err != nil || !jsonValuesEqual(result, expected) {
+			t.Error("Applying", example.patch, "to", example.original, "gave", result, err)
+		}
+	}
+}
+
+func TestParseJSONPatchRejectsMalformedPatches(t *testing.T) {
+	malformed := []string{
+		`{"op":"add","path":"/a","value":1}`,
+		`[{"op":"frobnicate","path":"/a"}]`,
+		`[{"op":"add","path":"/a"}]`,
+		`[{"op":"remove","path":"a"}]`,
+	}
+	for _, patch := range malformed {
+		if _, err := parseJSONPatch([]byte(patch)); err == nil {
+			t.Error("Malformed patch", patch, "was accepted")
+		}
+	}
+}
+
+func TestJSONPatchFish(t *testing.T) {
+	fish := pond[0]
+	endpoint := fmt.Sprint("/fish/", fish.ID)
+	suite := reveltest.NewTestSuite()
+
+	suite.Patch(endpoint, jsonPatchContentType, strings.NewReader(`[
+		{"op": "test", "path": "/color", "value": "Red"},
+		{"op": "add", "path": "/tags/-", "value": "shiny"},
+		{"op": "remove", "path": "/tags/0"}
+	]`))
+	suite.AssertOk()
+	patched := Fish{}
+	err := json.Unmarshal(suite.ResponseBody, &patched)
+	suite.Assert(err == nil)
+	suite.AssertEqual(fmt.Sprint([]string{"fast", "shiny"}), fmt.Sprint(patched.Tags))
+	suite.AssertEqual(fish.FinCount, patched.FinCount)
+
+	// a failing test operation aborts the whole patch
+	suite.Patch(endpoint, jsonPatchContentType, strings.NewReader(`[
+		{"op": "add", "path": "/tags/-", "value": "shiny"},
+		{"op": "test", "path": "/color", "value": "Green"}
+	]`))
+	suite.AssertStatus(http.StatusConflict)
+
+	suite.Patch(endpoint, jsonPatchContentType, strings.NewReader(`[
+		{"op": "replace", "path": "/CreateDate", "value": "2000-01-01T00:00:00Z"}
+	]`))
+	suite.AssertStatus(http.StatusUnprocessableEntity)
+	suite.AssertContains("immutable")
+
+	// nor can the ID, however it is reached
+	for _, op := range []string{
+		`{"op": "replace", "path": "/id", "value": 8}`,
+		`{"op": "remove", "path": "/id"}`,
+		`{"op": "move", "from": "/fin_count", "path": "/id"}`,
+		`{"op": "copy", "from": "/fin_count", "path": "/id"}`,
+		`{"op": "move", "from": "/id", "path": "/fin_count"}`,
+	} {
+		suite.Patch(endpoint, jsonPatchContentType, strings.NewReader("[" + op + "]"))
+		suite.AssertStatus(http.StatusUnprocessableEntity)
+		suite.AssertContains("identifies the Fish")
+	}
+	suite.Patch(endpoint, jsonPatchContentType, strings.NewReader(fmt.Sprint(`[{"op": "test", "path": "/id", "value": `, fish.ID, `}]`)))
+	suite.AssertOk()
+
+	// nor hidden attributes, however deep
+	for _, op := range []string{
+		`{"op": "copy", "from": "/owner/password", "path": "/color"}`,
+		`{"op": "test", "path": "/owner", "value": {}}`,
+		`{"op": "replace", "path": "/owner/is_admin", "value": true}`,
+	} {
+		suite.Patch(endpoint, jsonPatchContentType, strings.NewReader("[" + op + "]"))
+		suite.AssertStatus(http.StatusUnprocessableEntity)
+		suite.AssertNotContains(fish.Owner.Password)
+	}
+
+	// nor the whole document at once
+	suite.Patch(endpoint, jsonPatchContentType, strings.NewReader(`[{"op": "replace", "path": "", "value": {"id": 7}}]`))
+	suite.AssertStatus(http.StatusUnprocessableEntity)
+	suite.AssertContains("whole Fish")
+
+	suite.Patch(endpoint, jsonPatchContentType, strings.NewReader(`[{"op": "remove", "path": "/nope"}]`))
+	suite.AssertStatus(http.StatusConflict)
+
+	suite.Patch(endpoint, jsonPatchContentType, strings.NewReader(`{"color": "Blue"}`))
+	suite.AssertStatus(http.StatusBadRequest)
+
+	// patched models are still validated
+	suite.Patch(endpoint, jsonPatchContentType, strings.NewReader(`[{"op": "replace", "path": "/fin_count", "value": 1}]`))
+	suite.AssertStatus(http.StatusUnprocessableEntity)
+}
+
+func TestJSONPatchHiddenAttributes(t *testing.T) {
+	suite := reveltest.NewTestSuite()
+	owner, other := usersDB[0], usersDB[1]
+	combination := lockerRoom[1].Combination
+
+	for _, op := range []string{
+		`{"op": "copy", "from": "/combination", "path": "/label"}`,
+		`{"op": "move", "from": "/combination", "path": "/label"}`,
+		`{"op": "test", "path": "/combination", "value": "` + combination + `"}`,
+		`{"op": "test", "path": "/Combination", "value": "` + combination + `"}`,
+	} {
+		patchLockerAs(&suite, owner, "[" + op + "]")
+		suite.AssertStatus(http.StatusUnprocessableEntity)
+		suite.AssertNotContains(combination)
+	}
+	suite.AssertEqual("Fishing rods", lockerRoom[1].Label)
+
+	patchLockerAs(&suite, owner, `[{"op": "replace", "path": "/label", "value": "Nets"}]`)
+	suite.AssertOk()
+	suite.AssertEqual(combination, lockerRoom[1].Combination)
+
+	// callers who may not modify the model learn nothing from how the patch would apply
+	for _, guess := range []string{combination, "0000"} {
+		patchLockerAs(&suite, other, `[{"op": "test", "path": "/label", "value": "` + guess + `"}]`)
+		suite.AssertStatus(http.StatusForbidden)
+		patchLockerAs(&suite, nil, `[{"op": "test", "path": "/label", "value": "` + guess + `"}]`)
+		suite.AssertStatus(http.StatusUnauthorized)
+	}
+}
@@ -0,0 +1,416 @@
+package apikit
+
+import (
+	"github.com/revel/revel"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	jwtHS256 string = "HS256"
+	jwtRS256 string = "RS256"
+	jwtES256 string = "ES256"
+)
+
+var (
+	errInvalidToken  = errors.New("Invalid token")
+	errExpiredToken  = errors.New("Token has expired")
+	errEarlyToken    = errors.New("Token is not valid yet")
+	errTokenIssuer   = errors.New("Token was not issued by a trusted issuer")
+	errTokenAudience = errors.New("Token is not intended for this audience")
+	errTokenSubject  = errors.New("Token subject is not a known User")
+)
+
+// The claims of a verified JWT
+type JWTClaims map[string]interface{}
+
+// The sub claim
+func (claims JWTClaims) Subject() string {
+	sub, _ := claims["sub"].(string)
+	return sub
+}
+
+// The space-separated scope claim, or the scp claim as issued by some providers
+func (claims JWTClaims) Scopes() []string {
+	switch scopes := claims["scope"].(type) {
+	case string:
+		return strings.Fields(scopes)
+	}
+	switch scopes := claims["scp"].(type) {
+	case string:
+		return strings.Fields(scopes)
+	case []interface{}:
+		return stringsOf(scopes)
+	}
+	return nil
+}
+
+func (claims JWTClaims) HasScope(scope string) bool {
+	for _, s := range claims.Scopes() {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolves the sub claim of a verified JWT to a User, or nil if there is none
+type JWTUserLookup func(subject string) User
+
+type jwtKey struct {
+	id  string
+	alg string
+	// []byte for HS256, *rsa.PublicKey for RS256, *ecdsa.PublicKey for ES256
+	key interface{}
+}
+
+type jwtClaimsContextKey struct{}
+
+type jwtAuthenticator struct {
+	lookup  JWTUserLookup
+	load    sync.Once
+	keys    []jwtKey
+	loadErr error
+}
+
+// Authenticates JWT bearer tokens signed with HS256, RS256 or ES256.
+// Keys are read from app.conf on the first request that carries a token, and if they cannot be loaded,
+// requests that carry tokens are answered 500:
+// apikit.jwt.secret for HS256, apikit.jwt.publickey for a PEM file and apikit.jwt.jwks for a local JWKS file.
+// Tokens must carry an exp claim; iss and aud are checked against apikit.jwt.issuer and apikit.jwt.audience when set.
+func JWTAuthenticator(lookup JWTUserLookup) Authenticator {
+	return &jwtAuthenticator{lookup: lookup}
+}
+
+func (a *jwtAuthenticator) Authenticate(req *revel.Request) (User, error) {
+	authorization := req.Header.Get("Authorization")
+	if len(authorization) < 7 || !strings.EqualFold(authorization[:7], "Bearer ") {
+		return nil, nil
+	}
+	a.load.Do(func() {
+		a.keys, a.loadErr = loadJWTKeys()
+	})
+	if a.loadErr != nil {
+		return nil, authenticatorFailure{err: a.loadErr}
+	}
+
+	claims, err := verifyJWT(strings.TrimSpace(authorization[7:]), a.keys)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateJWTClaims(claims); err != nil {
+		return nil, err
+	}
+	user := a.lookup(claims.Subject())
+	if user == nil {
+		return nil, errTokenSubject
+	}
+	req.Request = req.Request.WithContext(context.WithValue(req.Context(), jwtClaimsContextKey{}, claims))
+	return user, nil
+}
+
+func (a *jwtAuthenticator) Challenge(realm string) string {
+	return "Bearer realm=" + strconv.Quote(realm)
+}
+
+// The claims of the JWT the User was authenticated with, or nil if they were not authenticated by a JWTAuthenticator
+func (c *GenericRESTController) JWTClaims() JWTClaims {
+	if c.Request == nil {
+		return nil
+	}
+	claims, _ := c.Request.Context().Value(jwtClaimsContextKey{}).(JWTClaims)
+	return claims
+}
+
+func verifyJWT(token string, keys []jwtKey) (JWTClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errInvalidToken
+	}
+	header := struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}{}
+	if err := decodeJWTSegment(parts[0], &header); err != nil {
+		return nil, errInvalidToken
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errInvalidToken
+	}
+
+	signed := []byte(parts[0] + "." + parts[1])
+	verified := false
+	for _, key := range keys {
+		if key.alg != header.Alg || (header.Kid != "" && key.id != "" && key.id != header.Kid) {
+			continue
+		}
+		if verifyJWTSignature(key, signed, signature) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, errInvalidToken
+	}
+
+	claims := JWTClaims{}
+	if err := decodeJWTSegment(parts[1], &claims); err != nil {
+		return nil, errInvalidToken
+	}
+	return claims, nil
+}
+
+func decodeJWTSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func verifyJWTSignature(key jwtKey, signed, signature []byte) bool {
+	switch key.alg {
+	case jwtHS256:
+		mac := hmac.New(sha256.New, key.key.([]byte))
+		mac.Write(signed)
+		return hmac.Equal(mac.Sum(nil), signature)
+	case jwtRS256:
+		digest := sha256.Sum256(signed)
+		return rsa.VerifyPKCS1v15(key.key.(*rsa.PublicKey), crypto.SHA256, digest[:], signature) == nil
+	case jwtES256:
+		if len(signature) != 64 {
+			return false
+		}
+		digest := sha256.Sum256(signed)
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		return ecdsa.Verify(key.key.(*ecdsa.PublicKey), digest[:], r, s)
+	}
+	return false
+}
+
+func validateJWTClaims(claims JWTClaims) error {
+	now := time.Now().Unix()
+	leeway := int64(revel.Config.IntDefault("apikit.jwt.leeway", 0))
+
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return errInvalidToken
+	}
+	if now > int64(exp)+leeway {
+		return errExpiredToken
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now < int64(nbf)-leeway {
+		return errEarlyToken
+	}
+
+	if issuer, found := revel.Config.String("apikit.jwt.issuer"); found && issuer != "" {
+		if iss, _ := claims["iss"].(string); iss != issuer {
+			return errTokenIssuer
+		}
+	}
+	if audience, found := revel.Config.String("apikit.jwt.audience"); found && audience != "" {
+		var audiences []string
+		switch aud := claims["aud"].(type) {
+		case string:
+			audiences = []string{aud}
+		case []interface{}:
+			audiences = stringsOf(aud)
+		}
+		if !containsString(audiences, audience) {
+			return errTokenAudience
+		}
+	}
+	return nil
+}
+
+func loadJWTKeys() ([]jwtKey, error) {
+	keys := []jwtKey{}
+	if secret, found := revel.Config.String("apikit.jwt.secret"); found && secret != "" {
+		keys = append(keys, jwtKey{alg: jwtHS256, key: []byte(secret)})
+	}
+	if keyPath, found := revel.Config.String("apikit.jwt.publickey"); found && keyPath != "" {
+		key, err := loadPEMPublicKey(keyPath)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	if jwksPath, found := revel.Config.String("apikit.jwt.jwks"); found && jwksPath != "" {
+		jwks, err := loadJWKS(jwksPath)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, jwks...)
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("JWT: none of apikit.jwt.secret, apikit.jwt.publickey or apikit.jwt.jwks is configured")
+	}
+	return keys, nil
+}
+
+func readConfiguredFile(path string) ([]byte, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(revel.BasePath, path)
+	}
+	return ioutil.ReadFile(path)
+}
+
+func loadPEMPublicKey(path string) (jwtKey, error) {
+	data, err := readConfiguredFile(path)
+	if err != nil {
+		return jwtKey{}, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return jwtKey{}, fmt.Errorf("JWT: %s is not a PEM file", path)
+	}
+
+	var pub interface{}
+	if block.Type == "CERTIFICATE" {
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return jwtKey{}, err
+		}
+		pub = cert.PublicKey
+	} else if pub, err = x509.ParsePKIXPublicKey(block.Bytes); err != nil {
+		return jwtKey{}, err
+	}
+
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		return jwtKey{alg: jwtRS256, key: pub}, nil
+	case *ecdsa.PublicKey:
+		if pub.Curve == elliptic.P256() {
+			return jwtKey{alg: jwtES256, key: pub}, nil
+		}
+	}
+	return jwtKey{}, fmt.Errorf("JWT: %s is neither an RSA nor a P-256 public key", path)
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+// Reads the signing keys of a JWKS file, skipping those of unsupported types
+func loadJWKS(path string) ([]jwtKey, error) {
+	data, err := readConfiguredFile(path)
+	if err != nil {
+		return nil, err
+	}
+	jwks := struct {
+		Keys []jsonWebKey `json:"keys"`
+	}{}
+	if err := json.Unmarshal(data, &jwks); err != nil {
+		return nil, err
+	}
+
+	keys := []jwtKey{}
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.jwtKey()
+		if err != nil {
+			return nil, fmt.Errorf("JWT: key %q of %s: %v", jwk.Kid, path, err)
+		}
+		if key != nil && (jwk.Alg == "" || jwk.Alg == key.alg) {
+			keys = append(keys, *key)
+		}
+	}
+	return keys, nil
+}
+
+func (jwk jsonWebKey) jwtKey() (*jwtKey, error) {
+	switch jwk.Kty {
+	case "oct":
+		k, err := base64.RawURLEncoding.DecodeString(jwk.K)
+		if err != nil {
+			return nil, err
+		}
+		return &jwtKey{id: jwk.Kid, alg: jwtHS256, key: k}, nil
+	case "RSA":
+		n, err := decodeJWKInt(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeJWKInt(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		return &jwtKey{id: jwk.Kid, alg: jwtRS256, key: &rsa.PublicKey{N: n, E: int(e.Int64())}}, nil
+	case "EC":
+		if jwk.Crv != "P-256" {
+			return nil, nil
+		}
+		x, err := decodeJWKInt(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeJWKInt(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+		if !pub.Curve.IsOnCurve(x, y) {
+			return nil, errors.New("point is not on P-256")
+		}
+		return &jwtKey{id: jwk.Kid, alg: jwtES256, key: pub}, nil
+	}
+	return nil, nil
+}
+
+func decodeJWKInt(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(data), nil
+}
+
+func stringsOf(values []interface{}) []string {
+	strs := []string{}
+	for _, v := range values {
+		if s, ok := v.(string); ok {
+			strs = append(strs, s)
+		}
+	}
+	return strs
+}
+
+func containsString(strs []string, s string) bool {
+	for _, str := range strs {
+		if str == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,264 @@
+package apikit
+
+import (
+	"github.com/revel/revel"
+	reveltest "github.com/revel/revel/testing"
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testJWTSecret   string = "a-secret-only-the-tests-know"
+	testJWTIssuer   string = "https://issuer.example.com"
+	testJWTAudience string = "revel-apikit-tests"
+	usersWriteScope string = "users:write"
+)
+
+var (
+	testRSAKey   *rsa.PrivateKey
+	testECKey    *ecdsa.PrivateKey
+	testPEMKey   *rsa.PrivateKey
+	testJWTCount int
+)
+
+// Generates keys up front, and configures them the way an app would in app.conf once the config is loaded
+func init() {
+	testRSAKey, _ = rsa.GenerateKey(rand.Reader, 2048)
+	testPEMKey, _ = rsa.GenerateKey(rand.Reader, 2048)
+	testECKey, _ = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	dir, err := ioutil.TempDir("", "apikit-jwt")
+	if err != nil {
+		panic(err)
+	}
+	jwks, _ := json.Marshal(map[string]interface{}{
+		"keys": []map[string]string{
+			{
+				"kty": "RSA",
+				"kid": "rsa-1",
+				"use": "sig",
+				"n": base64.RawURLEncoding.EncodeToString(testRSAKey.N.Bytes()),
+				"e": base64.RawURLEncoding.EncodeToString(exponentBytes(testRSAKey.E)),
+			},
+			{
+				"kty": "EC",
+				"kid": "ec-1",
+				"crv": "P-256",
+				"x": base64.RawURLEncoding.EncodeToString(testECKey.X.FillBytes(make([]byte, 32))),
+				"y": base64.RawURLEncoding.EncodeToString(testECKey.Y.FillBytes(make([]byte, 32))),
+			},
+			{
+				// encryption keys are not used to verify signatures
+				"kty": "RSA",
+				"kid": "enc-1",
+				"use": "enc",
+				"n": base64.RawURLEncoding.EncodeToString(testPEMKey.N.Bytes()),
+				"e": base64.RawURLEncoding.EncodeToString(exponentBytes(testPEMKey.E)),
+			},
+		},
+	})
+	jwksPath := filepath.Join(dir, "jwks.json")
+	ioutil.WriteFile(jwksPath, jwks, 0600)
+
+	der, _ := x509.MarshalPKIXPublicKey(&testPEMKey.PublicKey)
+	pemPath := filepath.Join(dir, "public.pem")
+	ioutil.WriteFile(pemPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0600)
+
+	startupHooks = append(startupHooks, func() {
+		revel.Config.SetOption("apikit.jwt.secret", testJWTSecret)
+		revel.Config.SetOption("apikit.jwt.jwks", jwksPath)
+		revel.Config.SetOption("apikit.jwt.publickey", pemPath)
+		revel.Config.SetOption("apikit.jwt.issuer", testJWTIssuer)
+		revel.Config.SetOption("apikit.jwt.audience", testJWTAudience)
+	})
+}
+
+func exponentBytes(e int) []byte {
+	return []byte{byte(e >> 16), byte(e >> 8), byte(e)}
+}
+
+// PUTHooker interface implementation; tokens must grant the users:write scope to modify users
+func (c *ExampleUserController) PrePUTHook(newInstance, existingInstance RESTObject, authUser User) revel.Result {
+	if claims := c.JWTClaims(); claims != nil && !claims.HasScope(usersWriteScope) {
+		return ApiMessage{
+			StatusCode: http.StatusForbidden,
+			Message: "Token lacks the " + usersWriteScope + " scope",
+		}
+	}
+	return nil
+}
+
+func (c *ExampleUserController) PostPUTHook(newInstance, existingInstance RESTObject, authUser User, err error) revel.Result {
+	return nil
+}
+
+func validClaims(subject string) map[string]interface{} {
+	testJWTCount++
+	return map[string]interface{}{
+		"sub": subject,
+		"iss": testJWTIssuer,
+		"aud": []string{"someone-else", testJWTAudience},
+		"exp": time.Now().Add(time.Hour).Unix(),
+		"jti": testJWTCount,
+		"scope": "users:read " + usersWriteScope,
+	}
+}
+
+func signJWT(alg, kid string, claims map[string]interface{}) string {
+	header := map[string]string{"alg": alg, "typ": "JWT"}
+	if kid != "" {
+		header["kid"] = kid
+	}
+	headerJSON, _ := json.Marshal(header)
+	claimsJSON, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(claimsJSON)
+	digest := sha256.Sum256([]byte(signed))
+
+	var signature []byte
+	switch alg {
+	case jwtHS256:
+		mac := hmac.New(sha256.New, []byte(testJWTSecret))
+		mac.Write([]byte(signed))
+		signature = mac.Sum(nil)
+	case jwtRS256:
+		key := testRSAKey
+		if kid == "" {
+			key = testPEMKey
+		}
+		signature, _ = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	case jwtES256:
+		r, s, _ := ecdsa.Sign(rand.Reader, testECKey, digest[:])
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func putMeWithToken(suite *reveltest.TestSuite, token string) {
+	putMeWith(suite, func(req *reveltest.TestRequest) {
+		req.Header.Set("Authorization", "Bearer " + token)
+	})
+}
+
+func TestJWTAlgorithms(t *testing.T) {
+	me := usersDB[0]
+	suite := reveltest.NewTestSuite()
+
+	for _, token := range []string{
+		signJWT(jwtHS256, "", validClaims(me.Username)),
+		signJWT(jwtRS256, "rsa-1", validClaims(me.Username)),
+		signJWT(jwtRS256, "", validClaims(me.Username)),
+		signJWT(jwtES256, "ec-1", validClaims(me.Username)),
+	} {
+		putMeWithToken(&suite, token)
+		suite.AssertOk()
+	}
+
+	// the sub claim identifies the User
+	putMeWithToken(&suite, signJWT(jwtHS256, "", validClaims(usersDB[1].Username)))
+	suite.AssertStatus(http.StatusForbidden)
+}
+
+func TestJWTRejected(t *testing.T) {
+	me := usersDB[0]
+	suite := reveltest.NewTestSuite()
+
+	expired := validClaims(me.Username)
+	expired["exp"] = time.Now().Add(-time.Minute).Unix()
+	early := validClaims(me.Username)
+	early["nbf"] = time.Now().Add(time.Minute).Unix()
+	noExpiry := validClaims(me.Username)
+	delete(noExpiry, "exp")
+	wrongIssuer := validClaims(me.Username)
+	wrongIssuer["iss"] = "https://evil.example.com"
+	wrongAudience := validClaims(me.Username)
+	wrongAudience["aud"] = "someone-else"
+
+	// somebody else's claims under my signature
+	mine := strings.Split(signJWT(jwtHS256, "", validClaims(me.Username)), ".")
+	theirs := strings.Split(signJWT(jwtHS256, "", validClaims(usersDB[1].Username)), ".")
+	tampered := strings.Join([]string{mine[0], theirs[1], mine[2]}, ".")
+
+	for _, token := range []string{
+		signJWT(jwtHS256, "", expired),
+		signJWT(jwtHS256, "", early),
+		signJWT(jwtHS256, "", noExpiry),
+		signJWT(jwtHS256, "", wrongIssuer),
+		signJWT(jwtRS256, "rsa-1", wrongAudience),
+		signJWT(jwtHS256, "", validClaims("nobody")),
+		// keys listed for encryption only
+		signJWT(jwtRS256, "enc-1", validClaims(me.Username)),
+		// the unsigned algorithm is never accepted
+		signJWT("none", "", validClaims(me.Username)),
+		tampered,
+		"not.a.token",
+	} {
+		putMeWithToken(&suite, token)
+		suite.AssertStatus(http.StatusUnauthorized)
+	}
+	challenges := suite.Response.Header[http.CanonicalHeaderKey("WWW-Authenticate")]
+	suite.Assert(containsString(challenges, `Bearer realm="revel-apikit"`))
+}
+
+func TestJWTKeyLoadingFailure(t *testing.T) {
+	pemPath, _ := revel.Config.String("apikit.jwt.publickey")
+	revel.Config.SetOption("apikit.jwt.publickey", filepath.Join(filepath.Dir(pemPath), "missing.pem"))
+	defer revel.Config.SetOption("apikit.jwt.publickey", pemPath)
+
+	httpReq, _ := http.NewRequest("PUT", "/user", nil)
+	httpReq.Header.Set("Authorization", "Bearer " + signJWT(jwtHS256, "", validClaims(usersDB[0].Username)))
+	user, err := JWTAuthenticator(testSessionLookup).Authenticate(revel.NewRequest(httpReq))
+	if _, failed := err.(authenticatorFailure); !failed || user != nil {
+		t.Fatal("Expected the missing key to fail authentication, got", user, err)
+	}
+	if msg := (&GenericRESTController{}).authenticationErrorMessage(err); msg.StatusCode != http.StatusInternalServerError {
+		t.Error("Expected a 500 when the keys cannot be loaded, got", msg.StatusCode)
+	}
+}
+
+func TestJWTClaimsInHooks(t *testing.T) {
+	me := usersDB[0]
+	suite := reveltest.NewTestSuite()
+
+	claims := validClaims(me.Username)
+	claims["scope"] = "users:read"
+	putMeWithToken(&suite, signJWT(jwtES256, "ec-1", claims))
+	suite.AssertStatus(http.StatusForbidden)
+	suite.AssertContains("lacks the " + usersWriteScope)
+
+	// Users authenticated without a token have no claims to check
+	putUrl := "http://" + net.JoinHostPort("localhost", strconv.Itoa(testPort)) + "/user"
+	body, _ := json.Marshal(me)
+	req := suite.PutCustom(putUrl, "application/json", bytes.NewReader(body))
+	req.SetBasicAuth(me.Username, me.Password)
+	req.MakeRequest()
+	suite.AssertOk()
+}
+
+func TestJWTClaimsScopes(t *testing.T) {
+	claims := JWTClaims{"scp": []interface{}{"a", "b"}}
+	if !claims.HasScope("b") || claims.HasScope("c") {
+		t.Error("scp claim not read as scopes:", claims.Scopes())
+	}
+	claims = JWTClaims{"scope": "a b"}
+	if len(claims.Scopes()) != 2 {
+		t.Error("scope claim not split:", claims.Scopes())
+	}
+}
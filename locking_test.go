@@ -0,0 +1,227 @@
+package apikit
+
+import (
+	"github.com/revel/revel"
+	reveltest "github.com/revel/revel/testing"
+	"encoding/json"
+	"bytes"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+// A model locked optimistically through its Revision
+type Note struct {
+	ID       uint64 `json:"id"`
+	Text     string `json:"text"`
+	Revision int    `json:"revision" apikit:"version"`
+}
+
+var (
+	notebook      = map[uint64]Note{}
+	notebookMutex sync.Mutex
+	lastNoteID    uint64
+)
+
+func (n *Note) CanBeViewedBy(user User) bool {
+	return true
+}
+
+func (n *Note) CanBeCreatedBy(user User) bool {
+	return true
+}
+
+func (n *Note) CanBeModifiedBy(user User) bool {
+	return true
+}
+
+func (n *Note) CanBeDeletedBy(user User) bool {
+	return true
+}
+
+func (n *Note) UniqueID() uint64 {
+	return n.ID
+}
+
+func (n *Note) Validate(v *revel.Validation) {
+
+}
+
+func (n *Note) Save() error {
+	notebookMutex.Lock()
+	defer notebookMutex.Unlock()
+	if n.ID == 0 {
+		lastNoteID++
+		n.ID = lastNoteID
+	} else if stored, ok := notebook[n.ID]; ok && stored.Revision != n.Revision - 1 {
+		// the version was checked before Save, but another request may have saved since
+		return ErrConflict{Message: fmt.Sprint("Note ", n.ID, " was saved by another request")}
+	}
+	notebook[n.ID] = *n
+	return nil
+}
+
+func (n *Note) Delete() error {
+	notebookMutex.Lock()
+	defer notebookMutex.Unlock()
+	delete(notebook, n.ID)
+	return nil
+}
+
+type NoteController struct {
+	*revel.Controller
+	GenericRESTController
+}
+
+func (c *NoteController) ModelFactory() RESTObject {
+	return &Note{}
+}
+
+func (c *NoteController) GetModelByID(id uint64) RESTObject {
+	notebookMutex.Lock()
+	defer notebookMutex.Unlock()
+	if note, ok := notebook[id]; ok {
+		return &note
+	}
+	return nil
+}
+
+func (c *NoteController) EnableGET() bool {
+	return true
+}
+
+func (c *NoteController) EnablePOST() bool {
+	return true
+}
+
+func (c *NoteController) EnablePUT() bool {
+	return true
+}
+
+func (c *NoteController) EnableDELETE() bool {
+	return true
+}
+
+func (c *NoteController) EnablePATCH() bool {
+	return true
+}
+
+func decodeNote(suite *reveltest.TestSuite) Note {
+	note := Note{}
+	err := json.Unmarshal(suite.ResponseBody, &note)
+	suite.Assert(err == nil)
+	return note
+}
+
+func TestVersionField(t *testing.T) {
+	suite := reveltest.NewTestSuite()
+
+	// the version of new models is not taken from the request
+	suite.Post("/notes", "application/json", bytes.NewBufferString(`{"text":"Feed the fish","revision":41}`))
+	suite.AssertOk()
+	created := decodeNote(&suite)
+	suite.AssertEqual(1, created.Revision)
+	suite.AssertHeader("ETag", `W/"1"`)
+
+	created.Text = "Feed the fish twice"
+	body, _ := json.Marshal(created)
+	suite.Put("/notes", "application/json", bytes.NewReader(body))
+	suite.AssertOk()
+	updated := decodeNote(&suite)
+	suite.AssertEqual(2, updated.Revision)
+	suite.AssertEqual(updated, notebook[created.ID])
+
+	// the first edit has already been saved, so the same one is in conflict
+	suite.Put("/notes", "application/json", bytes.NewReader(body))
+	suite.AssertStatus(http.StatusConflict)
+	suite.AssertContains("version 2, not 1")
+	suite.AssertEqual(2, notebook[created.ID].Revision)
+
+	endpoint := fmt.Sprint("/notes/", created.ID)
+	suite.Patch(endpoint, "application/merge-patch+json", bytes.NewBufferString(`{"text":"Stale","revision":1}`))
+	suite.AssertStatus(http.StatusConflict)
+
+	// patches must name the version they are based on, in their body or with If-Match
+	suite.Patch(endpoint, "application/merge-patch+json", bytes.NewBufferString(`{"text":"Feed the fish thrice"}`))
+	suite.AssertStatus(http.StatusPreconditionRequired)
+	suite.AssertContains("revision")
+	suite.Patch(endpoint, jsonPatchContentType, bytes.NewBufferString(`[{"op":"replace","path":"/text","value":"Nope"}]`))
+	suite.AssertStatus(http.StatusPreconditionRequired)
+	suite.AssertEqual(2, notebook[created.ID].Revision)
+
+	suite.Patch(endpoint, "application/merge-patch+json", bytes.NewBufferString(`{"text":"Feed the fish thrice","revision":2}`))
+	suite.AssertOk()
+	suite.AssertEqual(3, decodeNote(&suite).Revision)
+	suite.AssertHeader("ETag", `W/"3"`)
+
+	suite.Patch(endpoint, jsonPatchContentType, bytes.NewBufferString(`[
+		{"op":"test","path":"/revision","value":3},
+		{"op":"replace","path":"/text","value":"Feed the fish once more"}
+	]`))
+	suite.AssertOk()
+	suite.AssertEqual(4, decodeNote(&suite).Revision)
+
+	req := suite.PatchCustom(suite.BaseUrl() + endpoint, "application/merge-patch+json",
+		bytes.NewBufferString(`{"text":"Feed the fish at last"}`))
+	req.Header.Set("If-Match", `"4"`)
+	req.MakeRequest()
+	suite.AssertOk()
+	suite.AssertEqual(5, decodeNote(&suite).Revision)
+
+	// the tag can be sent back as it was received
+	for _, example := range []struct {
+		etag   string
+		status int
+	}{
+		{`W/"4"`, http.StatusPreconditionFailed},
+		{suite.Response.Header.Get("ETag"), http.StatusOK},
+	} {
+		req = suite.PatchCustom(suite.BaseUrl() + endpoint, "application/merge-patch+json",
+			bytes.NewBufferString(`{"text":"Feed the fish for good"}`))
+		req.Header.Set("If-Match", example.etag)
+		req.MakeRequest()
+		suite.AssertStatus(example.status)
+	}
+	suite.AssertEqual(6, decodeNote(&suite).Revision)
+
+	// encoding/json matches attributes regardless of case, so the version does too
+	suite.Patch(endpoint, "application/merge-patch+json", bytes.NewBufferString(`{"text":"Feed the fish","Revision":6}`))
+	suite.AssertOk()
+	suite.AssertEqual(7, decodeNote(&suite).Revision)
+	suite.Patch(endpoint, "application/merge-patch+json", bytes.NewBufferString(`{"text":"Stale","REVISION":6}`))
+	suite.AssertStatus(http.StatusConflict)
+}
+
+func TestVersionEnforcedBySave(t *testing.T) {
+	note := Note{Text: "Change the filter"}
+	note.Save()
+	// a request that read the note before this save passed checkVersion, but must not overwrite it
+	stale := note
+	note.Revision++
+	if err := note.Save(); err != nil {
+		t.Fatal(err)
+	}
+	stale.Revision++
+	if _, conflicted := stale.Save().(ErrConflict); !conflicted {
+		t.Error("Saving over a newer revision should conflict")
+	}
+}
+
+func TestVersionConflictProblem(t *testing.T) {
+	revel.Config.SetOption("apikit.problemdetails", "true")
+	defer revel.Config.SetOption("apikit.problemdetails", "false")
+	suite := reveltest.NewTestSuite()
+
+	note := Note{Text: "Clean the tank"}
+	note.Save()
+	note.Revision = 5
+	body, _ := json.Marshal(note)
+	suite.Put("/notes", "application/json", bytes.NewReader(body))
+	suite.AssertStatus(http.StatusConflict)
+	problem := map[string]interface{}{}
+	err := json.Unmarshal(suite.ResponseBody, &problem)
+	suite.Assert(err == nil)
+	suite.AssertEqual(float64(0), problem["current_version"])
+	suite.AssertEqual(float64(5), problem["given_version"])
+}
@@ -54,17 +54,38 @@ func TestMain(m *testing.M) {
 		},
 	})
 
-	RegisterRESTControllers([]RESTController{
-		(*ExampleUserController)(nil),
-		(*FishHookerController)(nil),
-		(*EmbeddedFishController)(nil),
+	// a module whose restcontroller-routes are imported by conf/restcontroller-routes
+	revel.Modules = append(revel.Modules, revel.Module{
+		Name: "fishtank",
+		Path: path.Join(cwd, "testdata", "fishtank"),
 	})
 
+	// EmbeddedFishController is also routed by conf/restcontroller-routes;
+	// the mounted routes must coexist with those
+	Mount("/v1/fish", (*EmbeddedFishController)(nil))
+	Mount("/apikeys", (*APIKeyController)(nil))
+	Mount("/notes", (*NoteController)(nil))
+	Mount("/crates", (*CrateController)(nil))
+	Mount("/lockers", (*LockerController)(nil))
+
+	RegisterRESTControllers(testRESTControllers)
+
 	go Run(testPort)
 	time.Sleep(time.Millisecond * 100)
 	os.Exit(m.Run())
 }
 
+// The RESTControllers routed by conf/restcontroller-routes
+var testRESTControllers = []RESTController{
+	(*ExampleUserController)(nil),
+	(*FishHookerController)(nil),
+	(*EmbeddedFishController)(nil),
+	(*GadgetController)(nil),
+	(*OwnedFishController)(nil),
+	(*APIKeyController)(nil),
+	(*SignupController)(nil),
+}
+
 // This method handles all requests.  It dispatches to handleInternal after
 // handling / adapting websocket connections.
 func handle(w http.ResponseWriter, r *http.Request) {
@@ -153,7 +174,13 @@ func Run(port int) {
 	}
 
 	// add the type injection filter
-	var modelFilter revel.Filter = CreateRESTControllerInjectionFilter(testAuthenticationFunc)
+	var modelFilter revel.Filter = CreateRESTControllerAuthenticatorFilter(
+		BasicAuthenticator(testAuthenticationFunc),
+		testTokenAuthenticator{},
+		SessionAuthenticator(testSessionKey, testSessionLookup),
+		JWTAuthenticator(testSessionLookup),
+		APIKeyAuthenticator(testUserByID),
+	)
 	filterCount := len(revel.Filters)
 	revel.Filters = append(revel.Filters[:filterCount-1],
 		append([]revel.Filter{modelFilter}, revel.Filters[filterCount-1:]...)...)
@@ -0,0 +1,90 @@
+package apikit
+
+import (
+	"github.com/revel/revel"
+	"path"
+	"reflect"
+	"regexp"
+)
+
+// A RESTController served beneath a path by Mount
+type mountPoint struct {
+	path       string
+	controller RESTController
+}
+
+var mountPoints []mountPoint
+
+// Serves the Actions a RESTController enables beneath mountPath, e.g. apikit.Mount("/users", (*UserController)(nil))
+// routes GET /users/:id, POST /users, PUT /users and DELETE /users/:id, plus List, PATCH and the bulk Actions
+// when they are available.
+// Must be called before RegisterRESTControllers. Mounted controllers do not also need to be passed to it.
+// In HTTP semantics mode, as configured when RegisterRESTControllers is called, the disabled Actions are routed too.
+// The Enable methods are consulted on a nil controller, so they must not depend on controller state.
+func Mount(mountPath string, c RESTController) {
+	mountPoints = append(mountPoints, mountPoint{
+		path: mountPath,
+		controller: c,
+	})
+}
+
+// Generates the routes of a mount point beneath prefix, e.g. "/v1"
+func (m mountPoint) routes(prefix string) []*revel.Route {
+	return standardRoutes(m.controller, path.Join("/", prefix, m.path), "apikit.Mount", 0)
+}
+
+// Generates a route for each generic Action a RESTController enables, served at collection or beneath it by ID.
+// In HTTP semantics mode, the Actions it disables are routed too, so that they answer 405 Method Not Allowed.
+func standardRoutes(c RESTController, collection, routesPath string, line int) []*revel.Route {
+	member := path.Join(collection, ":id")
+	name := reflect.TypeOf(c).Elem().Name()
+	routeDisabled := httpSemanticsEnabled()
+	var routes []*revel.Route
+	add := func(enabled bool, method, routePath, action string) {
+		if enabled || routeDisabled {
+			routes = append(routes, revel.NewRoute(method, routePath, name + "." + action, "", routesPath, line))
+		}
+	}
+	add(c.EnableGET(), "GET", member, "Get")
+	_, isLister := c.(ModelLister)
+	_, isScopedLister := c.(ScopedModelLister)
+	if isLister || isScopedLister {
+		add(c.EnableGET(), "GET", collection, "List")
+	}
+	add(c.EnablePOST(), "POST", collection, "Post")
+	add(c.EnablePUT(), "PUT", collection, "Put")
+	patchEnabler, isPatchEnabler := c.(PATCHEnabler)
+	add(isPatchEnabler && patchEnabler.EnablePATCH(), "PATCH", member, "Patch")
+	add(c.EnableDELETE(), "DELETE", member, "Delete")
+	if enabler, ok := c.(BulkEnabler); ok && enabler.EnableBulk() {
+		add(c.EnablePOST(), "POST", path.Join(collection, bulkPathSegment), "BulkPost")
+		add(c.EnablePUT(), "PUT", path.Join(collection, bulkPathSegment), "BulkPut")
+		add(c.EnableDELETE(), "DELETE", collection, "BulkDelete")
+	}
+	return routes
+}
+
+var routeParamPattern *regexp.Regexp = regexp.MustCompile(":[^/]+")
+
+// Two routes with the same key match the same requests, regardless of how their parameters are named
+func routeKey(route *revel.Route) string {
+	return routeParamPattern.ReplaceAllString(route.TreePath, ":")
+}
+
+// Generates the routes of every mount point, leaving out those overridden by the given routes
+func mountedRoutes(prefix string, overrides []*revel.Route) []*revel.Route {
+	overridden := map[string]bool{}
+	for _, route := range overrides {
+		overridden[routeKey(route)] = true
+	}
+
+	var routes []*revel.Route
+	for _, m := range mountPoints {
+		for _, route := range m.routes(prefix) {
+			if !overridden[routeKey(route)] {
+				routes = append(routes, route)
+			}
+		}
+	}
+	return routes
+}
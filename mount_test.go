@@ -0,0 +1,63 @@
+package apikit
+
+import (
+	reveltest "github.com/revel/revel/testing"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestMountedRoutes(t *testing.T) {
+	suite := reveltest.NewTestSuite()
+	fish := pond[0]
+	body, _ := json.Marshal(&fish)
+
+	suite.Put("/v1/fish", "application/json", bytes.NewReader(body))
+	suite.AssertOk()
+
+	suite.Delete(fmt.Sprint("/v1/fish/", fish.ID))
+	suite.AssertOk()
+
+	// EmbeddedFishController is neither a ModelLister nor a PATCHEnabler
+	suite.Get("/v1/fish")
+	suite.AssertNotFound()
+	suite.Patch(fmt.Sprint("/v1/fish/", fish.ID), "application/json", bytes.NewReader([]byte("{}")))
+	suite.AssertNotFound()
+}
+
+func TestMountedRouteOverride(t *testing.T) {
+	suite := reveltest.NewTestSuite()
+	// conf/restcontroller-routes routes this to FishHookerController
+	suite.Get(fmt.Sprint("/v1/fish/", luckyFishID))
+	suite.AssertOk()
+
+	msg := ApiMessage{}
+	err := json.Unmarshal(suite.ResponseBody, &msg)
+	suite.Assert(err == nil)
+	suite.AssertEqual(luckyFishMessage, msg.Message)
+}
+
+func TestMountPointRoutes(t *testing.T) {
+	// FishHookerController is not a ModelLister, so it has no List route
+	m := mountPoint{
+		path: "fish/",
+		controller: (*FishHookerController)(nil),
+	}
+	expected := []string{
+		"GET /v2/fish/:id FishHookerController.Get",
+		"POST /v2/fish FishHookerController.Post",
+		"PUT /v2/fish FishHookerController.Put",
+		"PATCH /v2/fish/:id FishHookerController.Patch",
+		"DELETE /v2/fish/:id FishHookerController.Delete",
+	}
+	routes := m.routes("/v2/")
+	if len(routes) != len(expected) {
+		t.Fatal("Expected", len(expected), "routes, got", len(routes))
+	}
+	for i, route := range routes {
+		if got := fmt.Sprint(route.Method, " ", route.Path, " ", route.Action); got != expected[i] {
+			t.Error("Expected route", expected[i], "got", got)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package apikit
+
+import (
+	"github.com/revel/revel"
+	reveltest "github.com/revel/revel/testing"
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+type Aquarium struct {
+	ID      uint64       `json:"id"`
+	Owner   *ExampleUser `json:"owner"`
+	Private bool         `json:"private"`
+	FishIDs []uint64     `json:"fish_ids"`
+}
+
+func (a *Aquarium) CanBeViewedBy(user User) bool {
+	return !a.Private || (user != nil && user.UniqueID() == a.Owner.UniqueID())
+}
+
+func (a *Aquarium) CanBeCreatedBy(user User) bool {
+	return false
+}
+
+func (a *Aquarium) CanBeModifiedBy(user User) bool {
+	return false
+}
+
+func (a *Aquarium) CanBeDeletedBy(user User) bool {
+	return false
+}
+
+func (a *Aquarium) UniqueID() uint64 {
+	return a.ID
+}
+
+func (a *Aquarium) Validate(v *revel.Validation) {
+
+}
+
+func (a *Aquarium) Delete() error {
+	return nil
+}
+
+func (a *Aquarium) Save() error {
+	return nil
+}
+
+func (a *Aquarium) contains(fishID uint64) bool {
+	for _, id := range a.FishIDs {
+		if id == fishID {
+			return true
+		}
+	}
+	return false
+}
+
+var aquariums []*Aquarium = []*Aquarium{
+	&Aquarium{
+		ID: 1,
+		Owner: usersDB[0],
+		FishIDs: []uint64{7},
+	},
+	&Aquarium{
+		ID: 2,
+		Owner: usersDB[1],
+		Private: true,
+		FishIDs: []uint64{8},
+	},
+}
+
+// Serves the fish in one aquarium at /aquariums/:aquariumId/fish
+type OwnedFishController struct {
+	*revel.Controller
+	GenericRESTController
+}
+
+func (c *OwnedFishController) ModelFactory() RESTObject {
+	return &Fish{}
+}
+
+func (c *OwnedFishController) GetModelByID(id uint64) RESTObject {
+	// never called for ParentScopedControllers
+	return nil
+}
+
+func (c *OwnedFishController) EnableGET() bool {
+	return true
+}
+
+func (c *OwnedFishController) EnablePOST() bool {
+	return true
+}
+
+func (c *OwnedFishController) EnablePUT() bool {
+	return false
+}
+
+func (c *OwnedFishController) EnableDELETE() bool {
+	return false
+}
+
+// ParentScopedController interface implementation
+func (c *OwnedFishController) ParentIDParams() []string {
+	return []string{"aquariumId"}
+}
+
+func (c *OwnedFishController) GetParentModel(parentIDs []string) RESTObject {
+	id, err := strconv.ParseUint(parentIDs[0], 10, 64)
+	if err != nil {
+		return nil
+	}
+	for _, a := range aquariums {
+		if a.ID == id {
+			return a
+		}
+	}
+	return nil
+}
+
+func (c *OwnedFishController) GetScopedModelByID(parentIDs []string, id interface{}) RESTObject {
+	aquarium, ok := c.GetParentModel(parentIDs).(*Aquarium)
+	if !ok || !aquarium.contains(id.(uint64)) {
+		return nil
+	}
+	for _, f := range pond {
+		if f.ID == id.(uint64) {
+			return &f
+		}
+	}
+	return nil
+}
+
+func (c *OwnedFishController) LinkToParent(child, parent RESTObject) {
+	child.(*Fish).Owner = parent.(*Aquarium).Owner
+}
+
+// ScopedModelLister interface implementation
+func (c *OwnedFishController) ListScopedModels(parent RESTObject, offset, limit int, authUser User) ([]RESTObject, int) {
+	aquarium := parent.(*Aquarium)
+	models := []RESTObject{}
+	for _, id := range aquarium.FishIDs {
+		models = append(models, c.GetScopedModelByID([]string{strconv.FormatUint(aquarium.ID, 10)}, id))
+	}
+	return models, len(models)
+}
+
+func TestGetNestedFish(t *testing.T) {
+	suite := reveltest.NewTestSuite()
+	suite.Get("/aquariums/1/fish/7")
+	suite.AssertOk()
+
+	fish := Fish{}
+	err := json.Unmarshal(suite.ResponseBody, &fish)
+	suite.Assert(err == nil)
+	suite.AssertEqual(uint64(7), fish.ID)
+
+	// fish 8 lives in another aquarium
+	suite.Get("/aquariums/1/fish/8")
+	suite.AssertStatus(http.StatusNotFound)
+
+	suite.Get("/aquariums/99/fish/7")
+	suite.AssertStatus(http.StatusNotFound)
+}
+
+func TestGetNestedFishInPrivateAquarium(t *testing.T) {
+	endpoint := "/aquariums/2/fish/8"
+	suite := reveltest.NewTestSuite()
+	suite.Get(endpoint)
+	suite.AssertStatus(http.StatusUnauthorized)
+
+	getUrl := "http://" + net.JoinHostPort("localhost", strconv.Itoa(testPort)) + endpoint
+	owner := usersDB[1]
+	req := suite.GetCustom(getUrl)
+	req.SetBasicAuth(owner.Username, owner.Password)
+	req.MakeRequest()
+	suite.AssertOk()
+}
+
+func TestPostNestedFish(t *testing.T) {
+	endpoint := "/aquariums/2/fish"
+	suite := reveltest.NewTestSuite()
+	body, _ := json.Marshal(&Fish{ID: 9, FinCount: 4, Color: "Blue"})
+
+	postUrl := "http://" + net.JoinHostPort("localhost", strconv.Itoa(testPort)) + endpoint
+	owner := usersDB[1]
+	req := suite.PostCustom(postUrl, "application/json", bytes.NewReader(body))
+	req.SetBasicAuth(owner.Username, owner.Password)
+	req.MakeRequest()
+	suite.AssertOk()
+
+	created := Fish{}
+	err := json.Unmarshal(suite.ResponseBody, &created)
+	suite.Assert(err == nil)
+	// the new fish belongs to the aquarium's owner
+	suite.Assert(created.Owner != nil)
+	suite.AssertEqual(owner.ID, created.Owner.ID)
+}
+
+func TestListNestedFish(t *testing.T) {
+	suite := reveltest.NewTestSuite()
+	suite.Get("/aquariums/1/fish")
+	suite.AssertOk()
+
+	page := struct {
+		Items []Fish `json:"items"`
+		Total int    `json:"total"`
+	}{}
+	err := json.Unmarshal(suite.ResponseBody, &page)
+	suite.Assert(err == nil)
+	suite.AssertEqual(1, page.Total)
+	suite.AssertEqual(uint64(7), page.Items[0].ID)
+}
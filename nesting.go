@@ -0,0 +1,73 @@
+package apikit
+
+import (
+	"github.com/revel/revel"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// A RESTController whose RESTObjects are owned by a parent RESTObject and routed beneath it,
+// e.g. /users/:userId/fish/:id
+type ParentScopedController interface {
+	RESTController
+	// Names of the route parameters that identify the parent, outermost first, e.g. []string{"userId"}
+	ParentIDParams() []string
+	// Loads the parent identified by the values of the ParentIDParams route parameters
+	GetParentModel(parentIDs []string) RESTObject
+	// Used in place of GetModelByID/GetModelByKey. Should only find children of the identified parent.
+	GetScopedModelByID(parentIDs []string, id interface{}) RESTObject
+	// Associates a POSTed, PUT or PATCHed child with its parent before it is validated and saved
+	LinkToParent(child, parent RESTObject)
+}
+
+// A ParentScopedController that can list the children of one parent.
+// List is not available to ParentScopedControllers that do not implement this.
+type ScopedModelLister interface {
+	ParentScopedController
+	ListScopedModels(parent RESTObject, offset, limit int, authUser User) ([]RESTObject, int)
+}
+
+// Loads the parent of a ParentScopedController's RESTObjects and ensures the authenticated user can view it.
+// Returns a nil parent for controllers that are not ParentScopedControllers.
+func (c *GenericRESTController) loadParent() (RESTObject, revel.Result) {
+	scoped, ok := c.modelProvider.(ParentScopedController)
+	if !ok {
+		return nil, nil
+	}
+
+	c.parentIDs = make([]string, len(scoped.ParentIDParams()))
+	for i, param := range scoped.ParentIDParams() {
+		if c.routeParams.Get(param) == "" {
+			// the routes file is missing a parent parameter for this Action
+			return nil, DefaultNotFoundMessage()
+		}
+		c.parentIDs[i] = c.routeParams.Get(param)
+	}
+
+	parent := scoped.GetParentModel(c.parentIDs)
+	if parent == nil {
+		return nil, ApiMessage{
+			StatusCode: http.StatusNotFound,
+			Message: fmt.Sprint("Parent with ID ", strings.Join(c.parentIDs, "/"), " not found"),
+			ProblemType: ProblemNotFound,
+			Extensions: map[string]interface{}{
+				"parent_ids": c.parentIDs,
+			},
+		}
+	}
+	if !c.Authorize(parent, "view") {
+		extensions := map[string]interface{}{
+			"parent_ids": c.parentIDs,
+			"action": "view",
+		}
+		return nil, c.denyAccess(fmt.Sprint("Unauthorized to view parent with ID ", strings.Join(c.parentIDs, "/")), extensions)
+	}
+	return parent, nil
+}
+
+func (c *GenericRESTController) linkToParent(child, parent RESTObject) {
+	if scoped, ok := c.modelProvider.(ParentScopedController); ok && parent != nil {
+		scoped.LinkToParent(child, parent)
+	}
+}
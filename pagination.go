@@ -0,0 +1,76 @@
+package apikit
+
+import (
+	"github.com/revel/revel"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+const (
+	defaultPageLimit int = 20
+	defaultMaxPageLimit int = 100
+)
+
+// A page of RESTObjects rendered by GenericRESTController.List, as presented to the viewer
+type ModelPage struct {
+	Items  []interface{} `json:"items" xml:"items>item"`
+	Total  int           `json:"total" xml:"total"`
+	Offset int           `json:"offset" xml:"offset"`
+	Limit  int           `json:"limit" xml:"limit"`
+	Next   string        `json:"next,omitempty" xml:"next,omitempty"`
+	Prev   string        `json:"prev,omitempty" xml:"prev,omitempty"`
+}
+
+// Reads the offset and limit query parameters of a List request,
+// falling back to the apikit.pagination.* values in app.conf
+func parsePageParams(query url.Values) (offset, limit int, errMsg *ApiMessage) {
+	limit = revel.Config.IntDefault("apikit.pagination.defaultlimit", defaultPageLimit)
+	maxLimit := revel.Config.IntDefault("apikit.pagination.maxlimit", defaultMaxPageLimit)
+
+	if raw := query.Get("offset"); raw != "" {
+		var err error
+		if offset, err = strconv.Atoi(raw); err != nil || offset < 0 {
+			return 0, 0, &ApiMessage{
+				StatusCode: http.StatusBadRequest,
+				Message: "offset must be a non-negative integer",
+				ProblemType: ProblemInvalidPage,
+			}
+		}
+	}
+	if raw := query.Get("limit"); raw != "" {
+		var err error
+		if limit, err = strconv.Atoi(raw); err != nil || limit < 1 {
+			return 0, 0, &ApiMessage{
+				StatusCode: http.StatusBadRequest,
+				Message: "limit must be a positive integer",
+				ProblemType: ProblemInvalidPage,
+			}
+		}
+	}
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+	return offset, limit, nil
+}
+
+// Builds the next/prev links for a page, preserving any other query parameters
+func (page *ModelPage) setLinks(requestURL *url.URL) {
+	link := func(offset int) string {
+		query := requestURL.Query()
+		query.Set("offset", strconv.Itoa(offset))
+		query.Set("limit", strconv.Itoa(page.Limit))
+		return requestURL.Path + "?" + query.Encode()
+	}
+
+	if page.Offset + page.Limit < page.Total {
+		page.Next = link(page.Offset + page.Limit)
+	}
+	if page.Offset > 0 {
+		prevOffset := page.Offset - page.Limit
+		if prevOffset < 0 {
+			prevOffset = 0
+		}
+		page.Prev = link(prevOffset)
+	}
+}
@@ -0,0 +1,112 @@
+package apikit
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+)
+
+const (
+	mergePatchContentType string = "application/merge-patch+json"
+)
+
+// Applies an RFC 7396 JSON Merge Patch to a decoded JSON document
+func mergePatch(target, patch interface{}) interface{} {
+	patchObj, ok := patch.(map[string]interface{})
+	if !ok {
+		// anything other than an object replaces the target wholesale
+		return patch
+	}
+	targetObj, ok := target.(map[string]interface{})
+	if !ok {
+		targetObj = map[string]interface{}{}
+	}
+	for key, value := range patchObj {
+		// encoding/json decodes members onto fields regardless of case, so a member replaces the one it folds onto
+		key = foldedMember(targetObj, key)
+		if value == nil {
+			delete(targetObj, key)
+		} else {
+			targetObj[key] = mergePatch(targetObj[key], value)
+		}
+	}
+	return targetObj
+}
+
+// The member of obj named key, or failing that one whose name equals key regardless of case, or else key itself
+func foldedMember(obj map[string]interface{}, key string) string {
+	if _, ok := obj[key]; ok {
+		return key
+	}
+	for member := range obj {
+		if strings.EqualFold(member, key) {
+			return member
+		}
+	}
+	return key
+}
+
+// Produces a new instance of the existing RESTObject with the merge patch applied to it.
+// The existing RESTObject is left untouched.
+func applyMergePatch(existing, instance RESTObject, patch []byte) error {
+	patchDoc, err := decodeJSONDocument(patch)
+	if err != nil {
+		return malformedPatch("Merge patch is not valid JSON")
+	}
+	if _, ok := patchDoc.(map[string]interface{}); !ok {
+		return malformedPatch("Merge patch must be a JSON object")
+	}
+
+	existingData, err := json.Marshal(existing)
+	if err != nil {
+		return err
+	}
+	existingDoc, err := decodeJSONDocument(existingData)
+	if err != nil {
+		return err
+	}
+
+	patchedData, err := json.Marshal(mergePatch(existingDoc, patchDoc))
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(patchedData, instance); err != nil {
+		return malformedPatch("Patched %s could not be decoded: %s", reflect.TypeOf(instance).Elem().Name(), err)
+	}
+	// fields that never make it into the JSON document would otherwise be zeroed out
+	return copyUnserializedAttributes(existing, instance)
+}
+
+// Copies struct fields tagged json:"-" from source to dest, descending into embedded structs
+func copyUnserializedAttributes(source, dest interface{}) error {
+	vOld, vNew := reflect.ValueOf(source), reflect.ValueOf(dest)
+	if vOld.Kind() != reflect.Ptr || vOld.Elem().Kind() != reflect.Struct {
+		return errors.New("Source is not a pointer to a struct")
+	}
+	if vNew.Kind() != reflect.Ptr || vNew.Elem().Kind() != reflect.Struct {
+		return errors.New("Destination is not a pointer to a struct")
+	}
+	vOld, vNew = vOld.Elem(), vNew.Elem()
+	if vOld.Type() != vNew.Type() {
+		return errors.New("Source and destination are not the same type")
+	}
+
+	for i := 0; i < vOld.NumField(); i++ {
+		fieldType := vOld.Type().Field(i)
+		newField := vNew.Field(i)
+		if !newField.CanSet() {
+			continue
+		}
+		if fieldType.Tag.Get("json") == "-" {
+			newField.Set(vOld.Field(i))
+		} else if fieldType.Anonymous && fieldType.Type.Kind() == reflect.Struct {
+			o := vOld.Field(i).Addr().Interface()
+			n := newField.Addr().Interface()
+			if err := copyUnserializedAttributes(o, n); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package apikit
+
+import (
+	"testing"
+	reveltest "github.com/revel/revel/testing"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+)
+
+func TestMergePatch(t *testing.T) {
+	// examples from RFC 7396, Appendix A
+	examples := []struct {
+		original, patch, result string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+		// members fold onto those of the target as encoding/json decodes them
+		{`{"revision":2,"text":"a"}`, `{"Revision":1,"TEXT":null}`, `{"revision":1}`},
+	}
+	for _, example := range examples {
+		var original, patch, expected interface{}
+		json.Unmarshal([]byte(example.original), &original)
+		json.Unmarshal([]byte(example.patch), &patch)
+		json.Unmarshal([]byte(example.result), &expected)
+
+		if result := mergePatch(original, patch); !reflect.DeepEqual(result, expected) {
+			t.Error("Merging", example.patch, "into", example.original, "gave", result)
+		}
+	}
+}
+
+func TestApplyMergePatchKeepsUnserializedAttributes(t *testing.T) {
+	existing := *usersDB[0]
+	patched := ExampleUser{}
+	if err := applyMergePatch(&existing, &patched, []byte(`{"favorite_color":"Green"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if patched.FavoriteColor != "Green" {
+		t.Error("Patched attribute was not applied")
+	}
+	if patched.Username != existing.Username {
+		t.Error("Attributes missing from the patch should be left alone")
+	}
+	if patched.Password != existing.Password {
+		t.Error("writeonly attributes should survive a merge patch")
+	}
+	if existing.FavoriteColor == "Green" {
+		t.Error("The existing model should not be modified")
+	}
+
+	if err := applyMergePatch(&existing, &patched, []byte(`["not", "an", "object"]`)); err == nil {
+		t.Error("Non-object merge patches should be rejected")
+	}
+}
+
+func TestPatchFish(t *testing.T) {
+	fish := pond[0]
+	endpoint := fmt.Sprint("/fish/", fish.ID)
+	suite := reveltest.NewTestSuite()
+
+	suite.Patch(endpoint, "application/merge-patch+json", strings.NewReader(`{"color": "Blue"}`))
+	suite.AssertOk()
+	patched := Fish{}
+	err := json.Unmarshal(suite.ResponseBody, &patched)
+	suite.Assert(err == nil)
+	suite.AssertEqual("Blue", patched.Color)
+	suite.AssertEqual(fish.FinCount, patched.FinCount)
+	suite.Assert(patched.Owner != nil && patched.Owner.ID == fish.Owner.ID)
+
+	// immutable attributes cannot be patched
+	newCreateDate := fish.CreateDate.Add(time.Hour * 100).Format(time.RFC3339Nano)
+	suite.Patch(endpoint, "application/merge-patch+json",
+		strings.NewReader(`{"CreateDate": "` + newCreateDate + `"}`))
+	suite.AssertOk()
+	patched = Fish{}
+	err = json.Unmarshal(suite.ResponseBody, &patched)
+	suite.Assert(err == nil)
+	suite.Assert(fish.CreateDate.Equal(patched.CreateDate))
+
+	// the patched model is validated
+	suite.Patch(endpoint, "application/merge-patch+json", strings.NewReader(`{"fin_count": 1}`))
+	suite.AssertStatus(http.StatusUnprocessableEntity)
+
+	// PATCHHooker can veto a patch
+	suite.Patch(endpoint, "application/merge-patch+json", strings.NewReader(`{"is_immortal": true}`))
+	suite.AssertStatus(http.StatusForbidden)
+	suite.AssertContains(fishImmortalityMessage)
+
+	// the patched model must still be the one in the URL
+	suite.Patch(endpoint, "application/merge-patch+json",
+		strings.NewReader(fmt.Sprint(`{"id": `, pond[1].ID, `, "color": "Blue"}`)))
+	suite.AssertStatus(http.StatusUnprocessableEntity)
+	suite.AssertContains("cannot change the ID")
+	suite.Patch(endpoint, "application/merge-patch+json", strings.NewReader(`{"id": null}`))
+	suite.AssertStatus(http.StatusUnprocessableEntity)
+	suite.Patch(endpoint, "application/merge-patch+json",
+		strings.NewReader(fmt.Sprint(`{"id": `, fish.ID, `, "color": "Blue"}`)))
+	suite.AssertOk()
+
+	suite.Patch(endpoint, "application/merge-patch+json", strings.NewReader(`not json`))
+	suite.AssertStatus(http.StatusBadRequest)
+
+	suite.Patch("/fish/12345", "application/merge-patch+json", strings.NewReader(`{"color": "Blue"}`))
+	suite.AssertStatus(http.StatusNotFound)
+	suite.AssertContains("12345")
+}
+
+func TestPatchNotEnabled(t *testing.T) {
+	suite := reveltest.NewTestSuite()
+	suite.Patch(fmt.Sprint("/user/", usersDB[0].ID), "application/merge-patch+json",
+		strings.NewReader(`{"favorite_color": "Green"}`))
+	suite.AssertStatus(http.StatusNotFound)
+}
@@ -0,0 +1,25 @@
+package apikit
+
+// A RESTObject that renders differently depending on who views it, e.g. hiding a User's email from strangers.
+// viewer is nil for anonymous requests.
+type Presenter interface {
+	RESTObject
+	Present(viewer User) interface{}
+}
+
+// A RESTController that renders its RESTObjects for each viewer itself, taking precedence over Presenter
+type ModelPresenter interface {
+	RESTController
+	PresentModel(model RESTObject, viewer User) interface{}
+}
+
+// The representation of model that GenericRESTController responds with to the authenticated User
+func (c *GenericRESTController) present(model RESTObject) interface{} {
+	if presenter, ok := c.modelProvider.(ModelPresenter); ok {
+		return presenter.PresentModel(model, c.authenticatedUser)
+	}
+	if presenter, ok := model.(Presenter); ok {
+		return presenter.Present(c.authenticatedUser)
+	}
+	return model
+}
@@ -0,0 +1,89 @@
+package apikit
+
+import (
+	reveltest "github.com/revel/revel/testing"
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+// What strangers see of an ExampleUser
+type publicExampleUser struct {
+	ID       uint64 `json:"id"`
+	Username string `json:"username"`
+}
+
+// Presenter interface implementation; only the User themself and admins see everything
+func (u *ExampleUser) Present(viewer User) interface{} {
+	if viewer != nil && (viewer.UniqueID() == u.ID || viewer.HasAdminPrivileges()) {
+		return u
+	}
+	return &publicExampleUser{
+		ID: u.ID,
+		Username: u.Username,
+	}
+}
+
+type presentedGadget struct {
+	*Gadget
+	Viewer string `json:"viewer"`
+}
+
+// ModelPresenter interface implementation
+func (c *GadgetController) PresentModel(model RESTObject, viewer User) interface{} {
+	presented := presentedGadget{Gadget: model.(*Gadget), Viewer: RoleAnonymous}
+	if user, ok := viewer.(*ExampleUser); ok {
+		presented.Viewer = user.Username
+	}
+	return presented
+}
+
+func TestPresenter(t *testing.T) {
+	me, somebodyElse := usersDB[0], usersDB[1]
+	endpoint := fmt.Sprint("/user/", me.ID)
+	suite := reveltest.NewTestSuite()
+
+	for _, viewer := range []*ExampleUser{nil, somebodyElse} {
+		requestAs(&suite, viewer, "GET", endpoint, nil)
+		suite.AssertOk()
+		suite.AssertContains(me.Username)
+		suite.AssertNotContains(me.FavoriteColor)
+	}
+
+	requestAs(&suite, me, "GET", endpoint, nil)
+	suite.AssertOk()
+	suite.AssertContains(me.FavoriteColor)
+
+	// pages present each item
+	requestAs(&suite, somebodyElse, "GET", "/user", nil)
+	suite.AssertOk()
+	page := struct {
+		Items []map[string]interface{} `json:"items"`
+	}{}
+	err := json.Unmarshal(suite.ResponseBody, &page)
+	suite.Assert(err == nil)
+	for _, item := range page.Items {
+		_, hasColor := item["favorite_color"]
+		suite.AssertEqual(item["id"] == float64(somebodyElse.ID), hasColor)
+	}
+}
+
+func TestModelPresenter(t *testing.T) {
+	gadget := gadgetShelf[0]
+	endpoint := "/gadgets/" + gadget.UUID
+	suite := reveltest.NewTestSuite()
+
+	suite.Get(endpoint)
+	suite.AssertOk()
+	presented := map[string]interface{}{}
+	err := json.Unmarshal(suite.ResponseBody, &presented)
+	suite.Assert(err == nil)
+	suite.AssertEqual(gadget.Name, presented["name"])
+	suite.AssertEqual(RoleAnonymous, presented["viewer"])
+
+	requestAs(&suite, usersDB[1], "GET", endpoint, nil)
+	suite.AssertOk()
+	err = json.Unmarshal(suite.ResponseBody, &presented)
+	suite.Assert(err == nil)
+	suite.AssertEqual(usersDB[1].Username, presented["viewer"])
+}
@@ -0,0 +1,136 @@
+package apikit
+
+import (
+	"github.com/revel/revel"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"sort"
+)
+
+const (
+	problemJSONContentType string = "application/problem+json"
+	problemXMLContentType  string = "application/problem+xml"
+	problemXMLNamespace    string = "urn:ietf:rfc:7807"
+)
+
+// The kinds of errors revel-apikit responds with. In problem details mode,
+// these are appended to apikit.problemdetails.typebase to form the problem's type URI.
+const (
+	ProblemBadRequest           string = "bad-request"
+	ProblemNotFound             string = "not-found"
+	ProblemNotAuthenticated     string = "not-authenticated"
+	ProblemNotAuthorized        string = "not-authorized"
+	ProblemForbidden            string = "forbidden"
+	ProblemConflict             string = "conflict"
+	ProblemSaveFailed           string = "save-failed"
+	ProblemInvalidID            string = "invalid-id"
+	ProblemInvalidPage          string = "invalid-page"
+	ProblemInvalidPatch         string = "invalid-patch"
+	ProblemTooManyItems         string = "too-many-items"
+	ProblemValidationFailed     string = "validation-failed"
+	ProblemNotAcceptable        string = "not-acceptable"
+	ProblemUnsupportedMediaType string = "unsupported-media-type"
+	ProblemMethodNotAllowed     string = "method-not-allowed"
+	ProblemPreconditionFailed   string = "precondition-failed"
+	ProblemPreconditionRequired string = "precondition-required"
+	ProblemIdempotencyKeyReused string = "idempotency-key-reused"
+	ProblemInternalServerError  string = "internal-server-error"
+)
+
+// An RFC 7807 problem details object.
+// Extensions are rendered as additional members alongside the standard ones.
+type ProblemDetails struct {
+	Type       string
+	Title      string
+	Status     int
+	Detail     string
+	Instance   string
+	Extensions map[string]interface{}
+}
+
+// Whether error responses are rendered as problem details, set by apikit.problemdetails in app.conf
+func problemDetailsEnabled() bool {
+	return revel.Config.BoolDefault("apikit.problemdetails", false)
+}
+
+func newProblemDetails(req *revel.Request, statusCode int, problemType, detail string, extensions map[string]interface{}) *ProblemDetails {
+	typeURI := "about:blank"
+	if problemType != "" {
+		typeURI = revel.Config.StringDefault("apikit.problemdetails.typebase", "urn:apikit:problem:") + problemType
+	}
+	return &ProblemDetails{
+		Type: typeURI,
+		Title: http.StatusText(statusCode),
+		Status: statusCode,
+		Detail: detail,
+		Instance: req.URL.RequestURI(),
+		Extensions: extensions,
+	}
+}
+
+func (problem *ProblemDetails) members() map[string]interface{} {
+	members := map[string]interface{}{}
+	for name, value := range problem.Extensions {
+		members[name] = value
+	}
+	members["type"] = problem.Type
+	members["title"] = problem.Title
+	members["status"] = problem.Status
+	if problem.Detail != "" {
+		members["detail"] = problem.Detail
+	}
+	if problem.Instance != "" {
+		members["instance"] = problem.Instance
+	}
+	return members
+}
+
+func (problem *ProblemDetails) MarshalJSON() ([]byte, error) {
+	return json.Marshal(problem.members())
+}
+
+// Renders the problem as described by RFC 7807 Appendix A
+func (problem *ProblemDetails) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start = xml.StartElement{
+		Name: xml.Name{Space: problemXMLNamespace, Local: "problem"},
+	}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	members := problem.members()
+	names := make([]string, 0, len(members))
+	for name := range members {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := e.EncodeElement(members[name], xml.StartElement{Name: xml.Name{Local: name}}); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+func (problem *ProblemDetails) Apply(req *revel.Request, resp *revel.Response) {
+	writeProblem(req, resp, nil, problem)
+}
+
+// Encodes a problem with the negotiated codec, using the problem media type for JSON and XML
+func writeProblem(req *revel.Request, resp *revel.Response, negotiated *registeredCodec, problem *ProblemDetails) {
+	codec := responseCodec(req, negotiated)
+	mediaType := codec.mediaType
+	switch codec.codec.(type) {
+	case JSONCodec:
+		mediaType = problemJSONContentType
+	case XMLCodec:
+		mediaType = problemXMLContentType
+	}
+	data, err := codec.codec.Marshal(problem)
+	if err != nil {
+		resp.WriteHeader(http.StatusInternalServerError, "text/plain")
+		return
+	}
+	resp.WriteHeader(problem.Status, mediaType)
+	resp.Out.Write(data)
+}
@@ -0,0 +1,100 @@
+package apikit
+
+import (
+	"github.com/revel/revel"
+	reveltest "github.com/revel/revel/testing"
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func enableProblemDetails() func() {
+	revel.Config.SetOption("apikit.problemdetails", "true")
+	return func() {
+		revel.Config.SetOption("apikit.problemdetails", "false")
+	}
+}
+
+func TestProblemDetailsNotFound(t *testing.T) {
+	defer enableProblemDetails()()
+	suite := reveltest.NewTestSuite()
+	suite.Get("/fish/12345")
+	suite.AssertNotFound()
+	suite.AssertContentType(problemJSONContentType)
+
+	problem := map[string]interface{}{}
+	err := json.Unmarshal(suite.ResponseBody, &problem)
+	suite.Assert(err == nil)
+	suite.AssertEqual("urn:apikit:problem:not-found", problem["type"])
+	suite.AssertEqual("Not Found", problem["title"])
+	suite.AssertEqual(float64(http.StatusNotFound), problem["status"])
+	suite.AssertEqual("Fish with ID 12345 not found", problem["detail"])
+	suite.AssertEqual("/fish/12345", problem["instance"])
+	suite.AssertEqual("Fish", problem["model"])
+	suite.AssertEqual("12345", problem["id"])
+}
+
+func TestProblemDetailsNotAuthorized(t *testing.T) {
+	defer enableProblemDetails()()
+	revel.Config.SetOption("apikit.problemdetails.typebase", "https://example.com/problems/")
+	defer revel.Config.SetOption("apikit.problemdetails.typebase", "urn:apikit:problem:")
+
+	suite := reveltest.NewTestSuite()
+	body, _ := json.Marshal(usersDB[0])
+	suite.Put("/user", "application/json", bytes.NewReader(body))
+	suite.AssertStatus(http.StatusUnauthorized)
+
+	problem := map[string]interface{}{}
+	err := json.Unmarshal(suite.ResponseBody, &problem)
+	suite.Assert(err == nil)
+	suite.AssertEqual("https://example.com/problems/not-authenticated", problem["type"])
+	suite.AssertEqual("modify", problem["action"])
+	suite.AssertEqual("ExampleUser", problem["model"])
+}
+
+func TestProblemDetailsValidation(t *testing.T) {
+	defer enableProblemDetails()()
+	suite := reveltest.NewTestSuite()
+	suite.Post("/fish", "application/json", strings.NewReader(`{"id": 99, "fin_count": 1}`))
+	suite.AssertStatus(http.StatusUnprocessableEntity)
+	suite.AssertContentType(problemJSONContentType)
+
+	problem := struct {
+		Type   string       `json:"type"`
+		Errors []FieldError `json:"errors"`
+	}{}
+	err := json.Unmarshal(suite.ResponseBody, &problem)
+	suite.Assert(err == nil)
+	suite.AssertEqual("urn:apikit:problem:validation-failed", problem.Type)
+	suite.AssertEqual(1, len(problem.Errors))
+	suite.AssertEqual("fin_count", problem.Errors[0].Key)
+}
+
+func TestProblemDetailsXML(t *testing.T) {
+	defer enableProblemDetails()()
+	getUrl := "http://" + net.JoinHostPort("localhost", strconv.Itoa(testPort)) + "/fish/12345"
+	suite := reveltest.NewTestSuite()
+	req := suite.GetCustom(getUrl)
+	req.Header.Set("Accept", "application/xml")
+	req.MakeRequest()
+	suite.AssertNotFound()
+	suite.AssertContentType(problemXMLContentType)
+	suite.AssertContains(`<problem xmlns="urn:ietf:rfc:7807">`)
+	suite.AssertContains("<status>404</status>")
+}
+
+func TestProblemDetailsDisabled(t *testing.T) {
+	suite := reveltest.NewTestSuite()
+	suite.Get("/fish/12345")
+	suite.AssertNotFound()
+	suite.AssertContentType("application/json")
+
+	msg := ApiMessage{}
+	err := json.Unmarshal(suite.ResponseBody, &msg)
+	suite.Assert(err == nil)
+	suite.AssertEqual(http.StatusNotFound, msg.StatusCode)
+}
@@ -0,0 +1,223 @@
+package apikit
+
+import (
+	"github.com/revel/revel"
+	"gopkg.in/yaml.v2"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const (
+	// Held by requests without an authenticated User
+	RoleAnonymous string = "anonymous"
+	// Held by every authenticated User
+	RoleAuthenticated string = "authenticated"
+	// Stands for every resource, or every verb
+	PolicyWildcard string = "*"
+)
+
+// A User with roles to be checked against the AccessPolicy
+type RoleBearer interface {
+	User
+	Roles() []string
+}
+
+// A RESTController that names the resource it serves in the AccessPolicy.
+// Otherwise the resource is named after the controller, lowercased and without the Controller suffix.
+type ResourceNamer interface {
+	RESTController
+	ResourceName() string
+}
+
+// Maps each role to the resources it may access, and each of those to the verbs it may use on them.
+// Verbs are the lowercased Action names: get, list, post, put, patch, delete and those of custom Actions.
+//
+//	viewer:
+//	  users: [get, list]
+//	editor:
+//	  users: [get, list, post, put, patch]
+//	  "*": [get]
+//
+// Only resources that the policy mentions are governed by it, unless a role is granted "*".
+type Policy map[string]map[string][]string
+
+// The Policy consulted before the CanBe*By checks of RESTObjects.
+// If nil, it is read from the file named by apikit.rbac.policy in app.conf, if any.
+var AccessPolicy Policy
+
+// Read by RegisterRESTControllers, so that a broken policy file stops the app from starting
+var configuredPolicy Policy
+
+// Reads a Policy from a YAML or JSON file
+func LoadPolicy(path string) (Policy, error) {
+	data, err := readConfiguredFile(path)
+	if err != nil {
+		return nil, err
+	}
+	policy := Policy{}
+	if err := yaml.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("RBAC: %s: %v", path, err)
+	}
+	return policy, nil
+}
+
+func loadConfiguredPolicy() error {
+	configuredPolicy = nil
+	if path, found := revel.Config.String("apikit.rbac.policy"); found && path != "" {
+		policy, err := LoadPolicy(path)
+		if err != nil {
+			return err
+		}
+		configuredPolicy = policy
+	}
+	return nil
+}
+
+func accessPolicy() Policy {
+	if AccessPolicy != nil {
+		return AccessPolicy
+	}
+	return configuredPolicy
+}
+
+// Whether the policy has anything to say about resource
+func (policy Policy) Governs(resource string) bool {
+	for _, resources := range policy {
+		if _, ok := resources[resource]; ok {
+			return true
+		}
+		if _, ok := resources[PolicyWildcard]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Whether any of roles may use verb on resource. Resources the policy does not govern are allowed.
+func (policy Policy) Allows(roles []string, resource, verb string) bool {
+	if !policy.Governs(resource) {
+		return true
+	}
+	for _, role := range roles {
+		for _, granted := range []string{resource, PolicyWildcard} {
+			for _, v := range policy[role][granted] {
+				if v == verb || v == PolicyWildcard {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// The verbs roles may use on each resource that they are granted anything on
+func (policy Policy) Permissions(roles []string) map[string][]string {
+	merged := map[string]map[string]bool{}
+	for _, role := range roles {
+		for resource, verbs := range policy[role] {
+			if merged[resource] == nil {
+				merged[resource] = map[string]bool{}
+			}
+			for _, verb := range verbs {
+				merged[resource][verb] = true
+			}
+		}
+	}
+
+	permissions := map[string][]string{}
+	for resource, verbs := range merged {
+		permissions[resource] = []string{}
+		for verb := range verbs {
+			permissions[resource] = append(permissions[resource], verb)
+		}
+		sort.Strings(permissions[resource])
+	}
+	return permissions
+}
+
+// The roles a User holds, including the built-in anonymous and authenticated roles
+func rolesOf(user User) []string {
+	if user == nil {
+		return []string{RoleAnonymous}
+	}
+	roles := []string{RoleAuthenticated}
+	if bearer, ok := user.(RoleBearer); ok {
+		roles = append(roles, bearer.Roles()...)
+	}
+	return roles
+}
+
+func resourceName(c RESTController) string {
+	if namer, ok := c.(ResourceNamer); ok {
+		return namer.ResourceName()
+	}
+	return strings.ToLower(strings.TrimSuffix(reflect.TypeOf(c).Elem().Name(), "Controller"))
+}
+
+// The bulk Actions are governed by the verbs of the Actions they apply to each item
+var bulkActionVerbs = map[string]string{
+	"BulkPost": "post",
+	"BulkPut": "put",
+	"BulkDelete": "delete",
+}
+
+// Checks the AccessPolicy before an Action runs, returning a result if the authenticated User may not run it
+func (c *GenericRESTController) checkAccessPolicy(action string) *ApiMessage {
+	if action == "Options" {
+		return nil
+	}
+	policy := accessPolicy()
+	resource := resourceName(c.modelProvider)
+	verb := strings.ToLower(action)
+	if itemVerb, ok := bulkActionVerbs[action]; ok {
+		verb = itemVerb
+	}
+	if policy == nil || policy.Allows(rolesOf(c.authenticatedUser), resource, verb) {
+		return nil
+	}
+	msg := c.denyAccess(fmt.Sprint("Not permitted to ", verb, " ", resource), map[string]interface{}{
+		"resource": resource,
+		"verb": verb,
+	})
+	return &msg
+}
+
+// The roles of a User and the permissions the AccessPolicy grants them
+type EffectivePermissions struct {
+	Roles       []string            `json:"roles" xml:"roles>role"`
+	Permissions map[string][]string `json:"permissions" xml:"-"`
+}
+
+// Reports the EffectivePermissions of the authenticated User. It is a plain revel controller, registered by
+// RegisterRESTControllers; route it with: GET /permissions PermissionsController.Show
+type PermissionsController struct {
+	*revel.Controller
+	authenticatedUser User
+}
+
+// AuthenticatedUserReceiver interface implementation
+func (c *PermissionsController) SetAuthenticatedUser(user User) {
+	c.authenticatedUser = user
+}
+
+func (c *PermissionsController) Show() revel.Result {
+	roles := rolesOf(c.authenticatedUser)
+	permissions := map[string][]string{}
+	if policy := accessPolicy(); policy != nil {
+		permissions = policy.Permissions(roles)
+	}
+	return HookJsonResult{
+		Body: &EffectivePermissions{
+			Roles: roles,
+			Permissions: permissions,
+		},
+	}
+}
+
+var permissionsMethodTypes = []*revel.MethodType{
+	&revel.MethodType{
+		Name: "Show",
+	},
+}
@@ -0,0 +1,150 @@
+package apikit
+
+import (
+	"github.com/revel/revel"
+	reveltest "github.com/revel/revel/testing"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+var testRoles map[string][]string = map[string][]string{
+	"MaxwellPayne": {"editor"},
+	"SmokeyTheBear": {"viewer"},
+}
+
+// RoleBearer interface implementation
+func (u *ExampleUser) Roles() []string {
+	return testRoles[u.Username]
+}
+
+// ResourceNamer interface implementation
+func (c *ExampleUserController) ResourceName() string {
+	return "users"
+}
+
+func enableAccessPolicy() func() {
+	policy, err := LoadPolicy("testdata/rbac.yaml")
+	if err != nil {
+		panic(err)
+	}
+	AccessPolicy = policy
+	return func() {
+		AccessPolicy = nil
+	}
+}
+
+func requestAs(suite *reveltest.TestSuite, user *ExampleUser, method, endpoint string, body []byte) {
+	url := "http://" + net.JoinHostPort("localhost", strconv.Itoa(testPort)) + endpoint
+	var req *reveltest.TestRequest
+	switch method {
+	case "GET":
+		req = suite.GetCustom(url)
+	case "PUT":
+		req = suite.PutCustom(url, "application/json", bytes.NewReader(body))
+	case "POST":
+		req = suite.PostCustom(url, "application/json", bytes.NewReader(body))
+	}
+	if user != nil {
+		req.SetBasicAuth(user.Username, user.Password)
+	}
+	req.MakeRequest()
+}
+
+func TestLoadPolicy(t *testing.T) {
+	policy, err := LoadPolicy("testdata/rbac.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Policy{
+		"anonymous": {"users": {"get"}},
+		"viewer": {"users": {"get", "list"}},
+		"editor": {"users": {"get", "list", "put", "resetpassword"}, "*": {"get"}},
+	}
+	if !reflect.DeepEqual(expected, policy) {
+		t.Error("unexpected policy:", policy)
+	}
+	if !policy.Allows([]string{"editor"}, "gadget", "get") || policy.Allows([]string{"viewer"}, "gadget", "put") {
+		t.Error("wildcard resource not applied")
+	}
+}
+
+func TestAccessPolicy(t *testing.T) {
+	defer enableAccessPolicy()()
+	editor, viewer := usersDB[0], usersDB[1]
+	suite := reveltest.NewTestSuite()
+
+	requestAs(&suite, nil, "GET", fmt.Sprint("/user/", editor.ID), nil)
+	suite.AssertOk()
+	requestAs(&suite, nil, "GET", "/user", nil)
+	suite.AssertStatus(http.StatusUnauthorized)
+	suite.AssertHeader("WWW-Authenticate", `Basic realm="revel-apikit"`)
+
+	requestAs(&suite, viewer, "GET", "/user", nil)
+	suite.AssertOk()
+
+	// the model would let viewers modify themselves, but the policy is checked first
+	body, _ := json.Marshal(viewer)
+	requestAs(&suite, viewer, "PUT", "/user", body)
+	suite.AssertStatus(http.StatusForbidden)
+	suite.AssertContains("Not permitted to put users")
+
+	body, _ = json.Marshal(editor)
+	requestAs(&suite, editor, "PUT", "/user", body)
+	suite.AssertOk()
+
+	// custom Actions are verbs too
+	requestAs(&suite, editor, "POST", fmt.Sprint("/user/", editor.ID, "/reset-password"), nil)
+	suite.AssertOk()
+	requestAs(&suite, viewer, "POST", fmt.Sprint("/user/", viewer.ID, "/reset-password"), nil)
+	suite.AssertStatus(http.StatusForbidden)
+
+	// the editor's wildcard grant governs every resource
+	gadget := "/gadgets/" + gadgetShelf[0].UUID
+	requestAs(&suite, viewer, "GET", gadget, nil)
+	suite.AssertStatus(http.StatusForbidden)
+	requestAs(&suite, editor, "GET", gadget, nil)
+	suite.AssertOk()
+}
+
+func TestEffectivePermissions(t *testing.T) {
+	defer enableAccessPolicy()()
+	suite := reveltest.NewTestSuite()
+
+	requestAs(&suite, usersDB[1], "GET", "/permissions", nil)
+	suite.AssertOk()
+	effective := EffectivePermissions{}
+	err := json.Unmarshal(suite.ResponseBody, &effective)
+	suite.Assert(err == nil)
+	suite.AssertEqual([]string{RoleAuthenticated, "viewer"}, effective.Roles)
+	suite.AssertEqual(map[string][]string{"users": {"get", "list"}}, effective.Permissions)
+
+	requestAs(&suite, nil, "GET", "/permissions", nil)
+	suite.AssertOk()
+	err = json.Unmarshal(suite.ResponseBody, &effective)
+	suite.Assert(err == nil)
+	suite.AssertEqual([]string{RoleAnonymous}, effective.Roles)
+	suite.AssertEqual(map[string][]string{"users": {"get"}}, effective.Permissions)
+
+	// PermissionsController is a plain revel controller, but invalid credentials are still refused
+	req := suite.GetCustom(suite.BaseUrl() + "/permissions")
+	req.Header.Set(testTokenHeader, "not a token")
+	req.MakeRequest()
+	suite.AssertStatus(http.StatusUnauthorized)
+}
+
+func TestBrokenPolicyFailsAtStartup(t *testing.T) {
+	revel.Config.SetOption("apikit.rbac.policy", "testdata/missing.yaml")
+	defer func() {
+		revel.Config.SetOption("apikit.rbac.policy", "")
+		loadConfiguredPolicy()
+	}()
+	if err := loadConfiguredPolicy(); err == nil {
+		t.Error("A policy file that cannot be read should stop RegisterRESTControllers")
+	}
+}
@@ -4,58 +4,131 @@ import (
 	"reflect"
 	"path"
 	"io/ioutil"
+	"os"
 	"strings"
 	"regexp"
 	"errors"
+	"fmt"
 	"github.com/robfig/pathtree"
 )
 
-// Register the RESTControllers
+// Register the RESTControllers, along with those that have been Mounted.
+// Routes in conf/restcontroller-routes, if it exists, take precedence over the routes generated by Mount.
+// The access policy named by apikit.rbac.policy is read here too, so a broken policy file panics at startup.
 func RegisterRESTControllers(controllers []RESTController) {
 	revel.MainRouter = revel.NewRouter(path.Join(revel.BasePath, "conf", "routes"))
 	revel.MainRouter.Refresh()
+	if err := loadConfiguredPolicy(); err != nil {
+		panic(err)
+	}
+	revel.RegisterController((*PermissionsController)(nil), permissionsMethodTypes)
 
+	registered := map[reflect.Type]bool{}
+	var restControllers []RESTController
+	for _, m := range mountPoints {
+		controllers = append(controllers, m.controller)
+	}
 	for _, c := range controllers {
-		revel.RegisterController(c,
-			[]*revel.MethodType{
-				&revel.MethodType{
-					Name: "Get",
-					Args: []*revel.MethodArg{
-						{"id", reflect.TypeOf((*uint64)(nil))},
-					},
-				},
-				&revel.MethodType{
-					Name: "Post",
-				},
-				&revel.MethodType{
-					Name: "Put",
-				},
-				&revel.MethodType{
-					Name: "Delete",
-					Args: []*revel.MethodArg{
-						{"id", reflect.TypeOf((*uint64)(nil))},
-					},
-				},
-			},
-		)
+		if !registered[reflect.TypeOf(c)] {
+			_, isKeyed := c.(IdentifiedBy)
+			_, isGETHooker := c.(GETHooker)
+			if _, isKeyedGETHooker := c.(KeyedGETHooker); isKeyed && isGETHooker && !isKeyedGETHooker {
+				revel.WARN.Println(reflect.TypeOf(c).Elem().Name(), "is IdentifiedBy an IDCodec, so its PreGETHook",
+					"is never called; implement KeyedGETHooker instead")
+			}
+			revel.RegisterController(c, restMethodTypes(c))
+			registered[reflect.TypeOf(c)] = true
+			restControllers = append(restControllers, c)
+		}
 	}
 
+	var restcontrollerRoutes []*revel.Route
 	restcontrollerPath := path.Join(revel.BasePath, "conf", "restcontroller-routes")
-	restcontrollerData, err := ioutil.ReadFile(restcontrollerPath)
-	if err != nil {
+	if restcontrollerData, err := ioutil.ReadFile(restcontrollerPath); err == nil {
+		restcontrollerRoutes, err = parseRoutes(restcontrollerPath, "", string(restcontrollerData), restControllers)
+		if err != nil {
+			panic(err)
+		}
+	} else if !os.IsNotExist(err) || len(mountPoints) == 0 {
 		panic(err)
 	}
 
-	restcontrollerRoutes, err := parseRoutes(restcontrollerPath, "", string(restcontrollerData))
-	if err != nil {
-		panic(err)
-	}
+	prefix := revel.Config.StringDefault("apikit.routes.prefix", "")
+	restcontrollerRoutes = append(restcontrollerRoutes, mountedRoutes(prefix, restcontrollerRoutes)...)
+	restcontrollerRoutes = append(restcontrollerRoutes, optionsRoutes(restcontrollerRoutes)...)
 
 	revel.MainRouter.Routes = append(revel.MainRouter.Routes, restcontrollerRoutes...)
 	updateTree(revel.MainRouter)
 }
 
-func parseRoutes(routesPath, joinedPath, content string) ([]*revel.Route, error) {
+// Describes the Actions of a RESTController: those of GenericRESTController plus any provided by an ActionProvider
+func restMethodTypes(c RESTController) []*revel.MethodType {
+	// parsed by the IDCodec, so that malformed IDs are not bound to zero values
+	idType := reflect.TypeOf((*string)(nil))
+	methods := []*revel.MethodType{
+		&revel.MethodType{
+			Name: "Get",
+			Args: []*revel.MethodArg{
+				{"id", idType},
+			},
+		},
+		&revel.MethodType{
+			Name: "List",
+		},
+		&revel.MethodType{
+			Name: "Post",
+		},
+		&revel.MethodType{
+			Name: "Put",
+		},
+		&revel.MethodType{
+			Name: "Patch",
+			Args: []*revel.MethodArg{
+				{"id", idType},
+			},
+		},
+		&revel.MethodType{
+			Name: "Delete",
+			Args: []*revel.MethodArg{
+				{"id", idType},
+			},
+		},
+		&revel.MethodType{
+			Name: "BulkPost",
+		},
+		&revel.MethodType{
+			Name: "BulkPut",
+		},
+		&revel.MethodType{
+			Name: "BulkDelete",
+		},
+		&revel.MethodType{
+			Name: "Options",
+		},
+	}
+
+	if provider, ok := c.(ActionProvider); ok {
+		resultType := reflect.TypeOf((*revel.Result)(nil)).Elem()
+		for _, action := range provider.Actions() {
+			for _, existing := range methods {
+				if strings.EqualFold(existing.Name, action.Name) {
+					panic(errors.New("ActionProvider: " + action.Name + " is already an Action of " +
+						reflect.TypeOf(c).Elem().Name()))
+				}
+			}
+			method, found := reflect.TypeOf(c).MethodByName(action.Name)
+			if !found || method.Type.NumOut() != 1 || method.Type.Out(0) != resultType ||
+				method.Type.NumIn() != len(action.Args) + 1 {
+				panic(errors.New("ActionProvider: " + reflect.TypeOf(c).Elem().Name() + "." + action.Name +
+					" must be a method taking the described Args and returning a revel.Result"))
+			}
+			methods = append(methods, action)
+		}
+	}
+	return methods
+}
+
+func parseRoutes(routesPath, joinedPath, content string, controllers []RESTController) ([]*revel.Route, error) {
 	var routes []*revel.Route
 
 	// For each line..
@@ -70,6 +143,11 @@ func parseRoutes(routesPath, joinedPath, content string) ([]*revel.Route, error)
 		// Handle included routes from modules.
 		// e.g. "module:testrunner" imports all routes from that module.
 		if strings.HasPrefix(line, modulePrefix) {
+			moduleRoutes, err := getModuleRoutes(line[len(modulePrefix):], joinedPath, controllers)
+			if err != nil {
+				return nil, routeError(err, routesPath, n)
+			}
+			routes = append(routes, moduleRoutes...)
 			continue
 		}
 
@@ -85,17 +163,28 @@ func parseRoutes(routesPath, joinedPath, content string) ([]*revel.Route, error)
 		if strings.HasSuffix(joinedPath, "/") && strings.HasPrefix(path, "/") {
 			joinedPath = joinedPath[0 : len(joinedPath)-1]
 		}
-		//path = strings.Join([]string{revel.BasePath, joinedPath, path}, "")
-		if strings.Contains(action, ":") {
-			return nil, errors.New("revel-apikit does not yet support catchall (:) actions")
-		}
+		path = joinedPath + path
 
 		// This will import the module routes under the path described in the
 		// routes file (joinedPath param). e.g. "* /jobs module:jobs" -> all
 		// routes' paths will have the path /jobs prepended to them.
 		// See #282 for more info
-		if strings.Contains(line, "*") {
-			return nil, errors.New("revel-apikit does not yet support wildcard (*) routes")
+		if method == "*" && strings.HasPrefix(action, modulePrefix) {
+			moduleRoutes, err := getModuleRoutes(action[len(modulePrefix):], path, controllers)
+			if err != nil {
+				return nil, routeError(err, routesPath, n)
+			}
+			routes = append(routes, moduleRoutes...)
+			continue
+		}
+
+		if strings.Contains(action, ":") {
+			expanded, err := expandCatchallRoute(method, path, action, fixedArgs, routesPath, n, controllers)
+			if err != nil {
+				return nil, routeError(err, routesPath, n)
+			}
+			routes = append(routes, expanded...)
+			continue
 		}
 
 		route := revel.NewRoute(method, path, action, fixedArgs, routesPath, n)
@@ -104,6 +193,147 @@ func parseRoutes(routesPath, joinedPath, content string) ([]*revel.Route, error)
 	return routes, nil
 }
 
+func routeError(err error, routesPath string, n int) error {
+	return fmt.Errorf("%s:%d: %s", routesPath, n + 1, err)
+}
+
+// Loads the conf/restcontroller-routes file of the given module beneath joinedPath
+func getModuleRoutes(moduleName, joinedPath string, controllers []RESTController) ([]*revel.Route, error) {
+	// Look up the module.  It may be not found due to the common case of e.g. the
+	// testrunner module being active only in dev mode.
+	module, found := revel.ModuleByName(moduleName)
+	if !found {
+		revel.INFO.Println("Skipping restcontroller-routes for inactive module", moduleName)
+		return nil, nil
+	}
+	routesPath := path.Join(module.Path, "conf", "restcontroller-routes")
+	content, err := ioutil.ReadFile(routesPath)
+	if err != nil {
+		return nil, err
+	}
+	return parseRoutes(routesPath, joinedPath, string(content), controllers)
+}
+
+// The HTTP method each generic Action is served by
+var actionMethods map[string]string = map[string]string{
+	"Get": "GET",
+	"List": "GET",
+	"Post": "POST",
+	"Put": "PUT",
+	"Patch": "PATCH",
+	"Delete": "DELETE",
+	"BulkPost": "POST",
+	"BulkPut": "PUT",
+	"BulkDelete": "DELETE",
+	"Options": "OPTIONS",
+}
+
+// Expands a route with a variable controller or action into one route per RESTController Action it can reach,
+// so that catchalls never reach methods outside the registered REST method set.
+// When the action is variable but the path has no matching parameter, the Action is chosen by HTTP method,
+// e.g. "* /users/:id UserController.:action" routes GET to Get, PATCH to Patch and DELETE to Delete.
+// The member parameter may have any name, but is routed as :id so that it binds to the id argument.
+func expandCatchallRoute(method, routePath, action, fixedArgs, routesPath string, line int, controllers []RESTController) ([]*revel.Route, error) {
+	actionSplit := strings.Split(action, ".")
+	if len(actionSplit) != 2 {
+		return nil, errors.New("Expected two parts (Controller.Action), but got " + action)
+	}
+	controllerName, methodName := actionSplit[0], actionSplit[1]
+	method = strings.ToUpper(method)
+
+	var routes []*revel.Route
+	matched := false
+	for _, c := range controllers {
+		name := reflect.TypeOf(c).Elem().Name()
+		cPath := routePath
+		if strings.HasPrefix(controllerName, ":") {
+			var found bool
+			if cPath, found = replaceRouteParam(cPath, controllerName[1:], strings.ToLower(name)); !found {
+				return nil, errors.New("Route path has no " + controllerName + " parameter")
+			}
+		} else if !strings.EqualFold(controllerName, name) {
+			continue
+		}
+		matched = true
+
+		if !strings.HasPrefix(methodName, ":") {
+			routes = append(routes, revel.NewRoute(method, cPath, name + "." + methodName, fixedArgs, routesPath, line))
+			continue
+		}
+
+		if _, found := replaceRouteParam(cPath, methodName[1:], ""); !found {
+			// choose the Action by HTTP method, among the routes that match the same requests as this one
+			for _, route := range standardRoutes(c, collectionPath(c, cPath), routesPath, line) {
+				if pathKey(route.Path) == pathKey(cPath) && (method == "*" || route.Method == method) {
+					routes = append(routes, route)
+				}
+			}
+			continue
+		}
+
+		for _, methodType := range restMethodTypes(c) {
+			actionMethod, isGeneric := actionMethods[methodType.Name]
+			if !isGeneric {
+				// Actions provided by an ActionProvider may be served by any HTTP method
+				actionMethod = "*"
+			}
+			if method != "*" && actionMethod != "*" && method != actionMethod {
+				continue
+			}
+			if method != "*" {
+				actionMethod = method
+			}
+			actionPath, _ := replaceRouteParam(cPath, methodName[1:], strings.ToLower(methodType.Name))
+			routes = append(routes, revel.NewRoute(actionMethod, actionPath, name + "." + methodType.Name,
+				fixedArgs, routesPath, line))
+		}
+	}
+	if !matched {
+		return nil, errors.New("Catchall actions are only supported for registered RESTControllers, not " + controllerName)
+	}
+	if len(routes) == 0 {
+		return nil, errors.New(action + " does not reach any Actions at " + routePath)
+	}
+	return routes, nil
+}
+
+// The collection a RESTController serves at a route path: the path without its last parameter if that names
+// a member of the collection, whatever it is called, or the path itself.
+// The parameters of ParentScopedControllers name their parent, and so are part of the collection.
+func collectionPath(c RESTController, routePath string) string {
+	segments := strings.Split(routePath, "/")
+	last := segments[len(segments) - 1]
+	if !strings.HasPrefix(last, ":") {
+		return routePath
+	}
+	if scoped, ok := c.(ParentScopedController); ok {
+		for _, param := range scoped.ParentIDParams() {
+			if last[1:] == param {
+				return routePath
+			}
+		}
+	}
+	return strings.Join(segments[:len(segments) - 1], "/")
+}
+
+// Two route paths with the same key match the same requests, regardless of how their parameters are named
+func pathKey(routePath string) string {
+	return routeParamPattern.ReplaceAllString(routePath, ":")
+}
+
+// Replaces the :param segment of a route path with value
+func replaceRouteParam(routePath, param, value string) (string, bool) {
+	segments := strings.Split(routePath, "/")
+	found := false
+	for i, segment := range segments {
+		if segment == ":" + param {
+			segments[i] = value
+			found = true
+		}
+	}
+	return strings.Join(segments, "/"), found
+}
+
 // Groups:
 // 1: method
 // 4: path
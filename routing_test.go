@@ -0,0 +1,121 @@
+package apikit
+
+import (
+	reveltest "github.com/revel/revel/testing"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestWildcardMethodRoutes(t *testing.T) {
+	suite := reveltest.NewTestSuite()
+	fish := pond[0]
+
+	suite.Get(fmt.Sprint("/wildfish/", fish.ID))
+	suite.AssertOk()
+	found := Fish{}
+	err := json.Unmarshal(suite.ResponseBody, &found)
+	suite.Assert(err == nil)
+	suite.AssertEqual(fish.ID, found.ID)
+
+	body, _ := json.Marshal(&fish)
+	suite.Put("/wildfish", "application/json", bytes.NewReader(body))
+	suite.AssertOk()
+
+	suite.Delete(fmt.Sprint("/wildfish/", fish.ID))
+	suite.AssertOk()
+
+	// FishHookerController is not a ModelLister
+	suite.Get("/wildfish")
+	suite.AssertNotFound()
+}
+
+func TestCatchallActionRoutes(t *testing.T) {
+	suite := reveltest.NewTestSuite()
+
+	suite.Get("/catchall/user/list")
+	suite.AssertOk()
+
+	suite.Get(fmt.Sprint("/catchall/user/get?id=", usersDB[0].ID))
+	suite.AssertOk()
+	user := ExampleUser{}
+	err := json.Unmarshal(suite.ResponseBody, &user)
+	suite.Assert(err == nil)
+	suite.AssertEqual(usersDB[0].Username, user.Username)
+
+	// not an Action of ExampleUserController
+	suite.Get("/catchall/user/somecustommethod")
+	suite.AssertNotFound()
+
+	// Post is not served by GET
+	suite.Post("/catchall/user/post", "application/json", bytes.NewReader([]byte("{}")))
+	suite.AssertNotFound()
+	suite.Get("/catchall/user/post")
+	suite.AssertNotFound()
+}
+
+func TestModuleRoutes(t *testing.T) {
+	suite := reveltest.NewTestSuite()
+	suite.Get(fmt.Sprint("/tank/fish/", pond[0].ID))
+	suite.AssertOk()
+}
+
+func TestCatchallRequiresRESTController(t *testing.T) {
+	controllers := []RESTController{(*ExampleUserController)(nil)}
+	_, err := expandCatchallRoute("GET", "/panic/:action", "PanicFilterTestController.:action", "", "routes", 0, controllers)
+	if err == nil {
+		t.Error("Catchalls should not reach controllers that are not RESTControllers")
+	}
+
+	_, err = expandCatchallRoute("GET", "/:controller/:action", ":controller.:action", "", "routes", 0, controllers)
+	if err != nil {
+		t.Error("Expected catchall over every RESTController to succeed, got", err)
+	}
+
+	_, err = expandCatchallRoute("GET", "/users/:id/:action", ":controller.:action", "", "routes", 0, controllers)
+	if err == nil {
+		t.Error("Variable controllers must be named by a route parameter")
+	}
+}
+
+func TestWildcardMethodRoutesByParameter(t *testing.T) {
+	examples := []struct {
+		path       string
+		controller RESTController
+		expected   []string
+	}{
+		{"/strays/:fishId", (*FishHookerController)(nil), []string{
+			"GET /strays/:id FishHookerController.Get",
+			"PATCH /strays/:id FishHookerController.Patch",
+			"DELETE /strays/:id FishHookerController.Delete",
+		}},
+		{"/tanks/:aquariumId/fish/:fishId", (*OwnedFishController)(nil), []string{
+			"GET /tanks/:aquariumId/fish/:id OwnedFishController.Get",
+		}},
+		// the last parameter names the parent here, not a fish
+		{"/tanks/:aquariumId", (*OwnedFishController)(nil), []string{
+			"GET /tanks/:aquariumId OwnedFishController.List",
+			"POST /tanks/:aquariumId OwnedFishController.Post",
+		}},
+	}
+	for _, example := range examples {
+		name := reflect.TypeOf(example.controller).Elem().Name()
+		routes, err := expandCatchallRoute("*", example.path, name + ".:action", "", "routes", 0,
+			[]RESTController{example.controller})
+		if err != nil {
+			t.Error(example.path, err)
+			continue
+		}
+		if len(routes) != len(example.expected) {
+			t.Error("Expected", len(example.expected), "routes at", example.path, "got", len(routes))
+			continue
+		}
+		for i, route := range routes {
+			if got := fmt.Sprint(route.Method, " ", route.Path, " ", route.Action); got != example.expected[i] {
+				t.Error("Expected route", example.expected[i], "got", got)
+			}
+		}
+	}
+}
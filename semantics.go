@@ -0,0 +1,179 @@
+package apikit
+
+import (
+	"github.com/revel/revel"
+	"net/http"
+	"path"
+	"reflect"
+	"strings"
+)
+
+// The methods probed for the Allow header, in the order they are listed
+var allowableMethods []string = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"}
+
+// Whether responses follow HTTP semantics more closely, set by apikit.httpsemantics in app.conf:
+// Post answers 201 Created with a Location, Delete answers 204 No Content,
+// disabled Actions answer 405 Method Not Allowed and OPTIONS requests are answered with the allowed methods.
+func httpSemanticsEnabled() bool {
+	return revel.Config.BoolDefault("apikit.httpsemantics", false)
+}
+
+// Answers OPTIONS requests with the methods allowed on the requested path.
+// Routed automatically for every path that RESTControllers serve, but only answered in HTTP semantics mode.
+func (c *GenericRESTController) Options() revel.Result {
+	if !httpSemanticsEnabled() {
+		return DefaultNotFoundMessage()
+	}
+	return noContentResult{
+		Headers: http.Header{
+			"Allow": []string{strings.Join(c.allowedMethods(), ", ")},
+		},
+	}
+}
+
+// The result of an Action the RESTController has disabled: 405 with an Allow header in HTTP semantics mode,
+// legacy otherwise
+func (c *GenericRESTController) disabledActionMessage(legacy ApiMessage) ApiMessage {
+	if !httpSemanticsEnabled() {
+		return legacy
+	}
+	allowed := c.allowedMethods()
+	return ApiMessage{
+		StatusCode: http.StatusMethodNotAllowed,
+		Message: c.Request.Method + " is not allowed here",
+		ProblemType: ProblemMethodNotAllowed,
+		Extensions: map[string]interface{}{
+			"allowed": allowed,
+		},
+		Headers: http.Header{
+			"Allow": []string{strings.Join(allowed, ", ")},
+		},
+	}
+}
+
+// The methods routed to enabled Actions of this RESTController at the requested path, along with OPTIONS
+func (c *GenericRESTController) allowedMethods() []string {
+	name := reflect.TypeOf(c.modelProvider).Elem().Name()
+	allowed := []string{}
+	for _, method := range allowableMethods {
+		probe := &http.Request{Method: method, URL: c.Request.URL, Header: http.Header{}}
+		match := revel.MainRouter.Route(probe)
+		if match == nil || match.Action == "404" || !strings.EqualFold(match.ControllerName, name) {
+			continue
+		}
+		if c.actionEnabled(match.MethodName) {
+			allowed = append(allowed, method)
+		}
+	}
+	return append(allowed, "OPTIONS")
+}
+
+// Whether the RESTController serves the given Action. Actions of ActionProviders are always served.
+func (c *GenericRESTController) actionEnabled(action string) bool {
+	switch strings.ToLower(action) {
+	case "get":
+		return c.modelProvider.EnableGET()
+	case "list":
+		_, isLister := c.modelProvider.(ModelLister)
+		_, isScopedLister := c.modelProvider.(ScopedModelLister)
+		return (isLister || isScopedLister) && c.modelProvider.EnableGET()
+	case "post":
+		return c.modelProvider.EnablePOST()
+	case "put":
+		return c.modelProvider.EnablePUT()
+	case "patch":
+		enabler, ok := c.modelProvider.(PATCHEnabler)
+		return ok && enabler.EnablePATCH()
+	case "delete":
+		return c.modelProvider.EnableDELETE()
+	case "bulkpost":
+		return c.bulkEnabled() && c.modelProvider.EnablePOST()
+	case "bulkput":
+		return c.bulkEnabled() && c.modelProvider.EnablePUT()
+	case "bulkdelete":
+		return c.bulkEnabled() && c.modelProvider.EnableDELETE()
+	case "options":
+		return false
+	}
+	return true
+}
+
+// The path of a RESTObject created beneath the requested collection, found among the routes of Get, or ""
+func (c *GenericRESTController) location(model RESTObject) string {
+	if !c.modelProvider.EnableGET() {
+		// Get may be routed all the same, but only to answer 405
+		return ""
+	}
+	name := reflect.TypeOf(c.modelProvider).Elem().Name()
+	id := c.formatID(modelKey(model))
+	collection := strings.TrimSuffix(c.Request.URL.Path, "/")
+	var found string
+	for _, route := range revel.MainRouter.Routes {
+		if route.Method != "GET" || !strings.EqualFold(route.Action, name + ".Get") {
+			continue
+		}
+		expanded, ok := expandRoutePath(route.Path, id, c.routeParams.Get)
+		if !ok {
+			continue
+		}
+		if path.Dir(expanded) == collection {
+			// prefer the route beneath the collection the model was posted to
+			return expanded
+		}
+		if found == "" {
+			found = expanded
+		}
+	}
+	return found
+}
+
+// Fills in the parameters of a route's path, returning false if any of them is unknown
+func expandRoutePath(routePath, id string, param func(name string) string) (string, bool) {
+	segments := strings.Split(routePath, "/")
+	for i, segment := range segments {
+		if !strings.HasPrefix(segment, ":") && !strings.HasPrefix(segment, "*") {
+			continue
+		}
+		if name := segment[1:]; name == "id" {
+			segments[i] = id
+		} else if segments[i] = param(name); segments[i] == "" {
+			return "", false
+		}
+	}
+	return strings.Join(segments, "/"), true
+}
+
+// Generates an OPTIONS route for each path that the given routes serve, unless one is routed already
+func optionsRoutes(routes []*revel.Route) []*revel.Route {
+	routed := map[string]bool{}
+	for _, route := range routes {
+		if route.Method == "OPTIONS" {
+			routed[routeParamPattern.ReplaceAllString(route.Path, ":")] = true
+		}
+	}
+	var generated []*revel.Route
+	for _, route := range routes {
+		key := routeParamPattern.ReplaceAllString(route.Path, ":")
+		// routes of any method already answer OPTIONS themselves
+		if routed[key] || route.Method == "*" || strings.HasPrefix(route.ControllerName, ":") {
+			continue
+		}
+		routed[key] = true
+		generated = append(generated, revel.NewRoute("OPTIONS", route.Path, route.ControllerName + ".Options", "",
+			"apikit.httpsemantics", 0))
+	}
+	return generated
+}
+
+// A response without a body, 204 No Content
+type noContentResult struct {
+	Headers http.Header
+}
+
+func (result noContentResult) Apply(req *revel.Request, resp *revel.Response) {
+	for name, values := range result.Headers {
+		resp.Out.Header()[name] = values
+	}
+	resp.Status = http.StatusNoContent
+	resp.Out.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,116 @@
+package apikit
+
+import (
+	"github.com/revel/revel"
+	reveltest "github.com/revel/revel/testing"
+	"encoding/json"
+	"bytes"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func enableHTTPSemantics() func() {
+	revel.Config.SetOption("apikit.httpsemantics", "true")
+	return func() {
+		revel.Config.SetOption("apikit.httpsemantics", "false")
+	}
+}
+
+// Enables HTTP semantics mode and generates the routes again, as if the app had started in it
+func routeHTTPSemantics() func() {
+	disable := enableHTTPSemantics()
+	RegisterRESTControllers(testRESTControllers)
+	return func() {
+		disable()
+		RegisterRESTControllers(testRESTControllers)
+	}
+}
+
+func options(suite *reveltest.TestSuite, endpoint string) {
+	req, _ := http.NewRequest("OPTIONS", suite.BaseUrl() + endpoint, nil)
+	suite.NewTestRequest(req).MakeRequest()
+}
+
+func TestExpandRoutePath(t *testing.T) {
+	params := map[string]string{"aquariumId": "2"}
+	if expanded, ok := expandRoutePath("/aquariums/:aquariumId/fish/:id", "9", func(name string) string {
+		return params[name]
+	}); !ok || expanded != "/aquariums/2/fish/9" {
+		t.Error("Expected /aquariums/2/fish/9, got", expanded)
+	}
+	if _, ok := expandRoutePath("/tanks/:tankId/fish/:id", "9", func(name string) string {
+		return params[name]
+	}); ok {
+		t.Error("Paths with unknown parameters cannot be expanded")
+	}
+}
+
+func TestCreatedWithLocation(t *testing.T) {
+	defer enableHTTPSemantics()()
+	suite := reveltest.NewTestSuite()
+
+	suite.Post("/notes", "application/json", bytes.NewBufferString(`{"text":"Test the water"}`))
+	suite.AssertStatus(http.StatusCreated)
+	created := decodeNote(&suite)
+	suite.AssertHeader("Location", fmt.Sprint("/notes/", created.ID))
+	suite.Get(suite.Response.Header.Get("Location"))
+	suite.AssertOk()
+
+	// nested models are located beneath their parent
+	owner := usersDB[1]
+	body, _ := json.Marshal(&Fish{ID: 10, FinCount: 4, Color: "Green"})
+	req := suite.PostCustom(suite.BaseUrl() + "/aquariums/2/fish", "application/json", bytes.NewReader(body))
+	req.SetBasicAuth(owner.Username, owner.Password)
+	req.MakeRequest()
+	suite.AssertStatus(http.StatusCreated)
+	suite.AssertHeader("Location", "/aquariums/2/fish/10")
+}
+
+func TestDeleteNoContent(t *testing.T) {
+	defer enableHTTPSemantics()()
+	suite := reveltest.NewTestSuite()
+	note := Note{Text: "Drain the tank"}
+	note.Save()
+
+	suite.Delete(fmt.Sprint("/notes/", note.ID))
+	suite.AssertStatus(http.StatusNoContent)
+	suite.AssertEqual(0, len(suite.ResponseBody))
+	suite.Get(fmt.Sprint("/notes/", note.ID))
+	suite.AssertNotFound()
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	suite := reveltest.NewTestSuite()
+	suite.Put("/apikeys", "application/json", bytes.NewBufferString("{}"))
+	suite.AssertNotFound()
+
+	// mounted controllers only route their disabled Actions in HTTP semantics mode
+	defer routeHTTPSemantics()()
+	suite.Put("/apikeys", "application/json", bytes.NewBufferString("{}"))
+	suite.AssertStatus(http.StatusMethodNotAllowed)
+	suite.AssertHeader("Allow", "GET, HEAD, POST, OPTIONS")
+
+	suite.Patch("/apikeys/1", "application/merge-patch+json", bytes.NewBufferString("{}"))
+	suite.AssertStatus(http.StatusMethodNotAllowed)
+	suite.AssertHeader("Allow", "GET, HEAD, DELETE, OPTIONS")
+}
+
+func TestOptions(t *testing.T) {
+	suite := reveltest.NewTestSuite()
+	options(&suite, "/notes")
+	suite.AssertNotFound()
+
+	defer enableHTTPSemantics()()
+	options(&suite, "/crates")
+	suite.AssertStatus(http.StatusNoContent)
+	suite.AssertHeader("Allow", "POST, PUT, DELETE, OPTIONS")
+
+	options(&suite, "/notes/1")
+	suite.AssertStatus(http.StatusNoContent)
+	suite.AssertHeader("Allow", "GET, HEAD, PATCH, DELETE, OPTIONS")
+
+	options(&suite, "/aquariums/1/fish")
+	suite.AssertStatus(http.StatusNoContent)
+	suite.AssertHeader("Allow", "GET, HEAD, POST, OPTIONS")
+}
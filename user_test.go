@@ -11,17 +11,20 @@ import (
 	"strconv"
 	"time"
 	"errors"
+	"reflect"
 )
 
 var _ = fmt.Println
 
+const passwordResetMessage = "Check your email for a password reset link"
+
 type ExampleUser struct {
 	ID            uint64    `json:"id"`
 	Username      string    `json:"username"`
 	DateCreated   time.Time
 	FavoriteColor string    `json:"favorite_color"`
-	Password      string    `json:"-"`
-	IsAdmin       bool      `json:"is_admin"`
+	Password      string    `json:"password" apikit:"writeonly"`
+	IsAdmin       bool      `json:"is_admin" apikit:"admin"`
 }
 
 func (u *ExampleUser) CanBeViewedBy(other User) bool {
@@ -89,6 +92,41 @@ func (c *ExampleUserController) GetModelByID(id uint64) RESTObject {
 	return nil
 }
 
+// ModelLister interface implementation
+func (c *ExampleUserController) ListModels(offset, limit int, authUser User) ([]RESTObject, int) {
+	models := []RESTObject{}
+	for i := offset; i < len(usersDB) && i < offset + limit; i++ {
+		models = append(models, usersDB[i])
+	}
+	return models, len(usersDB)
+}
+
+// ActionProvider interface implementation
+func (c *ExampleUserController) Actions() []*revel.MethodType {
+	return []*revel.MethodType{
+		&revel.MethodType{
+			Name: "ResetPassword",
+			Args: []*revel.MethodArg{
+				{"id", reflect.TypeOf((*uint64)(nil))},
+			},
+		},
+	}
+}
+
+func (c *ExampleUserController) ResetPassword(id uint64) revel.Result {
+	user := c.GetModelByID(id)
+	if user == nil {
+		return DefaultNotFoundMessage()
+	}
+	if !c.Authorize(user, "modify") {
+		return c.DenyAccess("Not authorized to reset this password")
+	}
+	return ApiMessage{
+		StatusCode: http.StatusOK,
+		Message: passwordResetMessage,
+	}
+}
+
 func (c *ExampleUserController) EnableGET() bool {
 	return true
 }
@@ -105,6 +143,44 @@ func (c *ExampleUserController) EnableDELETE() bool {
 	return true
 }
 
+// A ModelLister that takes sign-ups, but never shows ExampleUsers to anyone
+type SignupController struct {
+	*revel.Controller
+	GenericRESTController
+}
+
+func (c *SignupController) ModelFactory() RESTObject {
+	return &ExampleUser{}
+}
+
+func (c *SignupController) GetModelByID(id uint64) RESTObject {
+	return nil
+}
+
+func (c *SignupController) ListModels(offset, limit int, authUser User) ([]RESTObject, int) {
+	models := []RESTObject{}
+	for _, u := range usersDB {
+		models = append(models, u)
+	}
+	return models, len(usersDB)
+}
+
+func (c *SignupController) EnableGET() bool {
+	return false
+}
+
+func (c *SignupController) EnablePOST() bool {
+	return true
+}
+
+func (c *SignupController) EnablePUT() bool {
+	return false
+}
+
+func (c *SignupController) EnableDELETE() bool {
+	return false
+}
+
 var usersDB []*ExampleUser = []*ExampleUser{
 	&ExampleUser{
 		ID: 1,
@@ -147,6 +223,55 @@ func TestGetExampleUser(t *testing.T) {
 	suite.AssertStatus(http.StatusNotFound)
 }
 
+func TestListExampleUsers(t *testing.T) {
+	suite := reveltest.NewTestSuite()
+	suite.Get("/user?limit=2")
+	suite.AssertOk()
+
+	page := struct {
+		Items  []ExampleUser `json:"items"`
+		Total  int           `json:"total"`
+		Offset int           `json:"offset"`
+		Limit  int           `json:"limit"`
+		Next   string        `json:"next"`
+		Prev   string        `json:"prev"`
+	}{}
+	err := json.Unmarshal(suite.ResponseBody, &page)
+	suite.Assert(err == nil)
+	suite.AssertEqual(len(usersDB), page.Total)
+	suite.AssertEqual(2, len(page.Items))
+	suite.AssertEqual(usersDB[0].ID, page.Items[0].ID)
+	suite.AssertEqual("/user?limit=2&offset=2", page.Next)
+	suite.AssertEqual("", page.Prev)
+
+	// follow the next link to the last page
+	suite.Get(page.Next)
+	suite.AssertOk()
+	page.Next, page.Prev = "", ""
+	err = json.Unmarshal(suite.ResponseBody, &page)
+	suite.Assert(err == nil)
+	suite.AssertEqual(1, len(page.Items))
+	suite.AssertEqual(usersDB[2].ID, page.Items[0].ID)
+	suite.AssertEqual("", page.Next)
+	suite.AssertEqual("/user?limit=2&offset=0", page.Prev)
+
+	suite.Get("/user?limit=banana")
+	suite.AssertStatus(http.StatusBadRequest)
+
+	// controllers that are not ModelListers have no collection endpoint
+	suite.Get("/fish")
+	suite.AssertStatus(http.StatusNotFound)
+
+	// nor do those that disable GET
+	suite.Get("/signups")
+	suite.AssertStatus(http.StatusBadRequest)
+	for _, route := range standardRoutes((*SignupController)(nil), "/signups", "", 0) {
+		if route.Method == "GET" {
+			t.Error("Expected no GET routes for SignupController, got", route.Path, route.Action)
+		}
+	}
+}
+
 func TestPostExampleUser(t *testing.T) {
 	endpoint := "/user"
 	postUrl := "http://" + net.JoinHostPort("localhost", strconv.Itoa(testPort)) + endpoint
@@ -185,7 +310,7 @@ func TestPutExampleUser(t *testing.T) {
 	req := suite.PutCustom(putUrl, "application/json", bytes.NewReader(modifiedUserData))
 	req.SetBasicAuth(somebodyElse.Username, somebodyElse.Password)
 	req.MakeRequest()
-	suite.AssertStatus(http.StatusUnauthorized)
+	suite.AssertStatus(http.StatusForbidden)
 
 	// should succeed with my authentication
 	req = suite.PutCustom(putUrl, "application/json", bytes.NewReader(modifiedUserData))
@@ -224,6 +349,54 @@ func TestDeleteExampleUser(t *testing.T) {
 	suite.AssertStatus(http.StatusUnauthorized)
 }
 
+func TestCustomAction(t *testing.T) {
+	me := usersDB[0]
+	endpoint := fmt.Sprint("/user/", me.ID, "/reset-password")
+	postUrl := "http://" + net.JoinHostPort("localhost", strconv.Itoa(testPort)) + endpoint
+	suite := reveltest.NewTestSuite()
+
+	// custom actions see the same authenticated user as the generic ones
+	suite.Post(endpoint, "application/json", bytes.NewReader(nil))
+	suite.AssertStatus(http.StatusUnauthorized)
+
+	somebodyElse := usersDB[1]
+	req := suite.PostCustom(postUrl, "application/json", bytes.NewReader(nil))
+	req.SetBasicAuth(somebodyElse.Username, somebodyElse.Password)
+	req.MakeRequest()
+	suite.AssertStatus(http.StatusForbidden)
+
+	req = suite.PostCustom(postUrl, "application/json", bytes.NewReader(nil))
+	req.SetBasicAuth(me.Username, me.Password)
+	req.MakeRequest()
+	suite.AssertOk()
+	suite.AssertContains(passwordResetMessage)
+
+	suite.Post("/user/1234/reset-password", "application/json", bytes.NewReader(nil))
+	suite.AssertStatus(http.StatusNotFound)
+}
+
+// declares an Action that it does not have
+type BrokenActionController struct {
+	ExampleUserController
+}
+
+func (c *BrokenActionController) Actions() []*revel.MethodType {
+	return []*revel.MethodType{
+		&revel.MethodType{
+			Name: "DoesNotExist",
+		},
+	}
+}
+
+func TestActionProviderValidation(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Declaring a missing Action should panic")
+		}
+	}()
+	restMethodTypes((*BrokenActionController)(nil))
+}
+
 func TestGetCustomMethod(t *testing.T) {
 	suite := reveltest.NewTestSuite()
 	suite.Get("/userscustomroute")
@@ -0,0 +1,59 @@
+package apikit
+
+import (
+	"github.com/revel/revel"
+	"net/http"
+)
+
+// A single failed validation check on a RESTObject
+type FieldError struct {
+	Key     string `json:"key" xml:"key"`
+	Message string `json:"message" xml:"message"`
+}
+
+// A revel.Result renderable object listing every validation error found on a RESTObject
+type ValidationErrorMessage struct {
+	StatusCode int          `json:"code" xml:"code"`
+	Message    string       `json:"message" xml:"message"`
+	Errors     []FieldError `json:"errors" xml:"errors>error"`
+
+	codec *registeredCodec
+}
+
+func (msg ValidationErrorMessage) Apply(req *revel.Request, resp *revel.Response) {
+	if problemDetailsEnabled() {
+		extensions := map[string]interface{}{
+			"errors": msg.Errors,
+		}
+		writeProblem(req, resp, msg.codec, newProblemDetails(req, msg.StatusCode, ProblemValidationFailed, msg.Message, extensions))
+		return
+	}
+	writeEncoded(req, resp, msg.StatusCode, msg.codec, &msg)
+}
+
+func (msg ValidationErrorMessage) withCodec(negotiated registeredCodec) revel.Result {
+	msg.codec = &negotiated
+	return msg
+}
+
+// Runs the RESTObject's Validate method, returning nil if it passed
+func validateModel(model RESTObject) *ValidationErrorMessage {
+	v := new(revel.Validation)
+	model.Validate(v)
+	if !v.HasErrors() {
+		return nil
+	}
+
+	msg := ValidationErrorMessage{
+		StatusCode: http.StatusUnprocessableEntity,
+		Message: http.StatusText(http.StatusUnprocessableEntity),
+		Errors: make([]FieldError, len(v.Errors)),
+	}
+	for i, err := range v.Errors {
+		msg.Errors[i] = FieldError{
+			Key: err.Key,
+			Message: err.Message,
+		}
+	}
+	return &msg
+}
@@ -0,0 +1,34 @@
+package apikit
+
+import (
+	"testing"
+	reveltest "github.com/revel/revel/testing"
+	"encoding/json"
+	"bytes"
+	"net/http"
+)
+
+func TestValidationErrorMessage(t *testing.T) {
+	suite := reveltest.NewTestSuite()
+	invalidFish := Fish{
+		FinCount: 0,
+	}
+	body, _ := json.Marshal(&invalidFish)
+	suite.Post("/fish", "application/json", bytes.NewReader(body))
+	suite.AssertStatus(http.StatusUnprocessableEntity)
+
+	msg := ValidationErrorMessage{}
+	err := json.Unmarshal(suite.ResponseBody, &msg)
+	suite.Assert(err == nil)
+	suite.AssertEqual(http.StatusUnprocessableEntity, msg.StatusCode)
+	suite.AssertEqual(1, len(msg.Errors))
+	suite.AssertEqual("fin_count", msg.Errors[0].Key)
+	suite.AssertEqual("Fish must have at least 2 fins", msg.Errors[0].Message)
+
+	// validation also runs on PUT, after immutable attributes are copied over
+	invalidFish = pond[0]
+	invalidFish.FinCount = 1
+	body, _ = json.Marshal(&invalidFish)
+	suite.Put("/fish", "application/json", bytes.NewReader(body))
+	suite.AssertStatus(http.StatusUnprocessableEntity)
+}